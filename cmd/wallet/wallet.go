@@ -1,7 +1,7 @@
 package main
 
 import (
-	"io/ioutil"
+	"fmt"
 	"os"
 	"path/filepath"
 
@@ -29,7 +29,10 @@ const (
 )
 
 const (
-	fWalletDir = "wallet-dir"
+	fWalletDir           = "wallet-dir"
+	fWalletLockTimeout   = "wallet-lock-timeout"
+	fWalletBackups       = "wallet-backups"
+	fWalletWatchInterval = "wallet-watch-interval"
 
 	fProxyDomain = "proxy-domain"
 	fProxyTLS    = "proxy-tls"
@@ -43,6 +46,9 @@ const (
 	fProduction  = "production"
 
 	fTest = "test"
+
+	fMigrateLabel    = "label"
+	fMigratePassword = "password"
 )
 
 func Flag(flag string, short ...string) string {
@@ -82,6 +88,20 @@ func init() {
 			Usage: "directory to store wallet files",
 			Value: filepath.Join(homeDir, DirRoot, DirChildWallets),
 		},
+		cli.DurationFlag{
+			Name:  Flag(fWalletLockTimeout),
+			Usage: "duration an unlocked encrypted wallet can be idle before it is locked (0 to disable)",
+		},
+		cli.IntFlag{
+			Name:  Flag(fWalletBackups),
+			Usage: "number of previous versions of each wallet file to keep as backups (0 to disable)",
+			Value: wallet.DefaultBackupCount,
+		},
+		cli.DurationFlag{
+			Name:  Flag(fWalletWatchInterval),
+			Usage: "interval at which the wallet directory is polled for changes made by others (0 to disable)",
+			Value: wallet.DefaultWatchInterval,
+		},
 		/*
 			<<< PROXY CONFIG >>>
 		*/
@@ -139,13 +159,33 @@ func init() {
 		},
 	}
 	app.Action = cli.ActionFunc(action)
+	app.Commands = cli.Commands{
+		{
+			Name:  "migrate",
+			Usage: "rewrite wallet files of older versions with the latest version",
+			Flags: cli.FlagsByName{
+				cli.StringFlag{
+					Name:  Flag(fMigrateLabel),
+					Usage: "label of the wallet to migrate (all wallets if empty)",
+				},
+				cli.StringFlag{
+					Name:  Flag(fMigratePassword),
+					Usage: "password of the encrypted wallets to migrate",
+				},
+			},
+			Action: cli.ActionFunc(migrateAction),
+		},
+	}
 }
 
 func action(ctx *cli.Context) error {
 	quit := util.CatchInterrupt()
 
 	var (
-		walletDir = ctx.String(fWalletDir)
+		walletDir           = ctx.String(fWalletDir)
+		walletLockTimeout   = ctx.Duration(fWalletLockTimeout)
+		walletBackups       = ctx.Int(fWalletBackups)
+		walletWatchInterval = ctx.Duration(fWalletWatchInterval)
 
 		proxyDomain = ctx.String(fProxyDomain)
 		proxyTLS    = ctx.BoolT(fProxyTLS)
@@ -170,25 +210,28 @@ func action(ctx *cli.Context) error {
 		log.Printf("Wallet is running in staging")
 	}
 
-	// Test mode changes.
+	// Prepare wallet. Test mode keeps wallet files in memory.
+	walletConfig := &wallet.ManagerConfig{
+		RootDir:       walletDir,
+		LockTimeout:   walletLockTimeout,
+		Backups:       walletBackups,
+		WatchInterval: walletWatchInterval,
+	}
 	if test {
-		tempDir, err := ioutil.TempDir(os.TempDir(), "kc_wallet")
-		if err != nil {
-			return err
-		}
-		defer os.RemoveAll(tempDir)
-		walletDir = tempDir
+		walletConfig.Storage = wallet.NewMemoryStorage()
+		walletConfig.BackupStorage = wallet.NewMemoryStorage()
 	}
-
-	// Prepare wallet.
-	walletManager, err := wallet.NewManager(&wallet.ManagerConfig{
-		RootDir: walletDir,
-	})
+	walletManager, err := wallet.NewManager(walletConfig)
 	if err != nil {
 		return err
 	}
-	log.Printf("INIT: wallet directory is '%s' (TEST:%v).",
-		walletDir, test)
+	defer walletManager.Close()
+	if test {
+		log.Printf("INIT: wallet files are kept in memory (TEST:%v).", test)
+	} else {
+		log.Printf("INIT: wallet directory is '%s' (TEST:%v).",
+			walletDir, test)
+	}
 
 	// Prepare proxy.
 	proxyManager, err := proxy.New(&proxy.Config{
@@ -227,6 +270,63 @@ func action(ctx *cli.Context) error {
 	return nil
 }
 
+func migrateAction(ctx *cli.Context) error {
+	var (
+		walletDir = ctx.GlobalString(fWalletDir)
+		label     = ctx.String(fMigrateLabel)
+		password  = ctx.String(fMigratePassword)
+	)
+	if ctx.GlobalBool(fProduction) {
+		walletDir = filepath.Join(homeDir, DirRoot, DirChildWalletsProd)
+	}
+
+	walletManager, err := wallet.NewManager(&wallet.ManagerConfig{
+		RootDir: walletDir,
+		Backups: ctx.GlobalInt(fWalletBackups),
+	})
+	if err != nil {
+		return err
+	}
+	defer walletManager.Close()
+	log.Printf("MIGRATE: wallet directory is '%s', latest version is %d.",
+		walletDir, wallet.Version)
+
+	var found, failed int
+	for _, stat := range walletManager.ListWallets() {
+		if label != "" && stat.Label != label {
+			continue
+		}
+		found++
+		switch {
+		case stat.UnsupportedVersion != 0:
+			log.Printf("SKIP: wallet '%s' is of version %d, which is newer than supported.",
+				stat.Label, stat.UnsupportedVersion)
+			continue
+		case stat.Encrypted && password == "":
+			log.Printf("SKIP: wallet '%s' is encrypted, and no password is given.",
+				stat.Label)
+			continue
+		}
+		migrated, err := walletManager.MigrateWallet(stat.Label, password)
+		switch {
+		case err != nil:
+			log.Printf("FAIL: wallet '%s': %v", stat.Label, err)
+			failed++
+		case migrated:
+			log.Printf("DONE: wallet '%s' is migrated.", stat.Label)
+		default:
+			log.Printf("SKIP: wallet '%s' is already of the latest version.", stat.Label)
+		}
+	}
+	if label != "" && found == 0 {
+		return wallet.ErrWalletNotFound
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to migrate %d wallet(s)", failed)
+	}
+	return nil
+}
+
 func main() {
 	if e := app.Run(os.Args); e != nil {
 		log.Println(e)
@@ -8,6 +8,6 @@ require (
 	github.com/sirupsen/logrus v1.4.2
 	github.com/skycoin/skycoin v0.22.0
 	github.com/stretchr/testify v1.3.0
-	golang.org/x/crypto v0.0.0-20190621222207-cc06ce4a13d4 // indirect
+	golang.org/x/crypto v0.0.0-20190621222207-cc06ce4a13d4
 	gopkg.in/urfave/cli.v1 v1.20.0
 )
@@ -0,0 +1,185 @@
+package http
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/watercompany/kittycash-wallet/src/wallet"
+)
+
+/*
+	<<< ERROR ENVELOPE >>>
+*/
+
+// ErrorCode identifies the kind of an error of the API. Unlike messages,
+// codes do not change, so clients can match on them.
+type ErrorCode string
+
+const (
+	CodeInvalidRequest     ErrorCode = "invalid_request"
+	CodeInvalidSeed        ErrorCode = "invalid_seed"
+	CodeValueNotInRange    ErrorCode = "value_not_in_range"
+	CodeWalletNotFound     ErrorCode = "wallet_not_found"
+	CodeAccountNotFound    ErrorCode = "account_not_found"
+	CodeEntryNotFound      ErrorCode = "entry_not_found"
+	CodeBackupNotFound     ErrorCode = "backup_not_found"
+	CodeWalletLocked       ErrorCode = "wallet_locked"
+	CodeInvalidPassword    ErrorCode = "invalid_password"
+	CodeInvalidPassphrase  ErrorCode = "invalid_passphrase"
+	CodeLabelExists        ErrorCode = "label_exists"
+	CodeInvalidLabel       ErrorCode = "invalid_label"
+	CodeLabelReserved      ErrorCode = "label_reserved"
+	CodeAccountExists      ErrorCode = "account_name_exists"
+	CodeInvalidAccount     ErrorCode = "invalid_account_name"
+	CodeEntryExists        ErrorCode = "entry_exists"
+	CodeNotEncrypted       ErrorCode = "wallet_not_encrypted"
+	CodeEncrypted          ErrorCode = "wallet_encrypted"
+	CodeQuarantined        ErrorCode = "wallet_quarantined"
+	CodeUnsupported        ErrorCode = "unsupported_version"
+	CodeCorruptFile        ErrorCode = "corrupt_file"
+	CodeWatchOnly          ErrorCode = "watch_only"
+	CodeNotHD              ErrorCode = "not_hd"
+	CodeInvalidBackup      ErrorCode = "invalid_backup"
+	CodeInvalidExtendedKey ErrorCode = "invalid_extended_key"
+	CodeNotWatchOnly       ErrorCode = "not_watch_only"
+	CodeInvalidDerivation  ErrorCode = "invalid_derivation"
+)
+
+// APIError is the error of an ErrorReply. Details are specific to the code,
+// if any.
+type APIError struct {
+	Code    ErrorCode   `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// ErrorReply is sent by every endpoint that fails.
+type ErrorReply struct {
+	Error APIError `json:"error"`
+}
+
+// RangeDetails are the details of CodeValueNotInRange.
+type RangeDetails struct {
+	Name  string `json:"name"`
+	Min   *int   `json:"min,omitempty"`
+	Max   *int   `json:"max,omitempty"`
+	Extra []int  `json:"extra,omitempty"`
+	Got   int    `json:"got"`
+}
+
+// SeedDetails are the details of CodeInvalidSeed, when a seed is not a valid
+// BIP39 mnemonic.
+type SeedDetails struct {
+	Reason   wallet.MnemonicErrorReason `json:"reason"`
+	Word     string                     `json:"word,omitempty"`
+	Position int                        `json:"position,omitempty"` // Position of word, starting at 1.
+}
+
+// FieldDetails are the details of CodeInvalidRequest, when a value of a
+// request is missing or invalid.
+type FieldDetails struct {
+	Field string `json:"field"`
+}
+
+type errorKind struct {
+	code   ErrorCode
+	status int
+}
+
+// errorKinds holds the code and status of each error of the wallet package
+// that is expected from requests.
+var errorKinds = map[error]errorKind{
+	wallet.ErrWalletNotFound:           {CodeWalletNotFound, http.StatusNotFound},
+	wallet.ErrFileNotFound:             {CodeWalletNotFound, http.StatusNotFound},
+	wallet.ErrAccountNotFound:          {CodeAccountNotFound, http.StatusNotFound},
+	wallet.ErrEntryNotFound:            {CodeEntryNotFound, http.StatusNotFound},
+	wallet.ErrFileBackupNotFound:       {CodeBackupNotFound, http.StatusNotFound},
+	wallet.ErrWalletLocked:             {CodeWalletLocked, http.StatusForbidden},
+	wallet.ErrInvalidPassword:          {CodeInvalidPassword, http.StatusForbidden},
+	wallet.ErrInvalidCredentials:       {CodeInvalidPassword, http.StatusForbidden},
+	wallet.ErrInvalidPassphrase:        {CodeInvalidPassphrase, http.StatusForbidden},
+	wallet.ErrLabelAlreadyExists:       {CodeLabelExists, http.StatusConflict},
+	wallet.ErrLabelInvalidChars:        {CodeInvalidLabel, http.StatusBadRequest},
+	wallet.ErrLabelReserved:            {CodeLabelReserved, http.StatusBadRequest},
+	wallet.ErrAccountNameExists:        {CodeAccountExists, http.StatusConflict},
+	wallet.ErrInvalidAccountName:       {CodeInvalidAccount, http.StatusBadRequest},
+	wallet.ErrEntryExists:              {CodeEntryExists, http.StatusConflict},
+	wallet.ErrWalletNotEncrypted:       {CodeNotEncrypted, http.StatusConflict},
+	wallet.ErrWalletEncrypted:          {CodeEncrypted, http.StatusConflict},
+	wallet.ErrWalletQuarantined:        {CodeQuarantined, http.StatusConflict},
+	wallet.ErrUnsupportedVersion:       {CodeUnsupported, http.StatusConflict},
+	wallet.ErrCorruptFile:              {CodeCorruptFile, http.StatusConflict},
+	wallet.ErrInvalidKDFParams:         {CodeCorruptFile, http.StatusConflict},
+	wallet.ErrInvalidNonce:             {CodeCorruptFile, http.StatusConflict},
+	wallet.ErrFileSize:                 {CodeCorruptFile, http.StatusConflict},
+	wallet.ErrWatchOnly:                {CodeWatchOnly, http.StatusConflict},
+	wallet.ErrAccountsUnsupported:      {CodeWatchOnly, http.StatusConflict},
+	wallet.ErrNotHD:                    {CodeNotHD, http.StatusConflict},
+	wallet.ErrInvalidBackup:            {CodeInvalidBackup, http.StatusBadRequest},
+	wallet.ErrUnsupportedBackupVersion: {CodeInvalidBackup, http.StatusBadRequest},
+	wallet.ErrInvalidExtendedKey:       {CodeInvalidExtendedKey, http.StatusBadRequest},
+	wallet.ErrNotWatchOnly:             {CodeNotWatchOnly, http.StatusConflict},
+	wallet.ErrMissingSeed:              {CodeInvalidSeed, http.StatusBadRequest},
+	wallet.ErrWatchOnlySeed:            {CodeInvalidSeed, http.StatusBadRequest},
+	wallet.ErrInvalidDerivation:        {CodeInvalidDerivation, http.StatusBadRequest},
+	wallet.ErrMissingPassword:          {CodeInvalidPassword, http.StatusBadRequest},
+}
+
+// sendError responds with the ErrorReply of e. Errors of the wallet package
+// (possibly wrapped with errors.Wrap) have their own code and status, and
+// other errors are of the given status, with a code of the status' text.
+func sendError(w http.ResponseWriter, status int, e error) error {
+	apiErr := APIError{
+		Code:    statusCode(status),
+		Message: e.Error(),
+	}
+	switch cause := errors.Cause(e).(type) {
+	case *wallet.MnemonicError:
+		apiErr.Code, status = CodeInvalidSeed, http.StatusBadRequest
+		details := SeedDetails{
+			Reason: cause.Reason,
+			Word:   cause.Word,
+		}
+		if cause.Reason == wallet.MnemonicUnknownWord {
+			details.Position = cause.Index + 1
+		}
+		apiErr.Details = details
+	case wallet.ErrValueNotInRange:
+		apiErr.Code, status = CodeValueNotInRange, http.StatusBadRequest
+		details := RangeDetails{
+			Name:  cause.ValName,
+			Extra: cause.Extra,
+			Got:   cause.Got,
+		}
+		if cause.HasMin || cause.ExpMin != 0 {
+			details.Min = &cause.ExpMin
+		}
+		if cause.HasMax || cause.ExpMax != 0 {
+			details.Max = &cause.ExpMax
+		}
+		apiErr.Details = details
+	case *fieldError:
+		apiErr.Code, status = CodeInvalidRequest, http.StatusBadRequest
+		if cause.field != "" {
+			apiErr.Details = FieldDetails{Field: cause.field}
+		}
+	default:
+		// Errors of types that are not comparable can not be looked up.
+		if reflect.TypeOf(cause).Comparable() {
+			if kind, ok := errorKinds[cause]; ok {
+				apiErr.Code, status = kind.code, kind.status
+			}
+		}
+	}
+	return sendJson(w, status, ErrorReply{Error: apiErr})
+}
+
+// statusCode obtains the ErrorCode of errors that only have a status, such
+// as "bad_request" or "internal_server_error".
+func statusCode(status int) ErrorCode {
+	text := strings.ToLower(http.StatusText(status))
+	return ErrorCode(strings.Replace(text, " ", "_", -1))
+}
@@ -0,0 +1,108 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/watercompany/kittycash-wallet/src/wallet"
+)
+
+func TestSendError(t *testing.T) {
+	three, ten := 3, 10
+	cases := []struct {
+		name    string
+		status  int
+		err     error
+		expCode ErrorCode
+		expStat int
+		expDet  interface{}
+	}{
+		{
+			name:    "wallet_error",
+			status:  http.StatusBadRequest,
+			err:     wallet.ErrWalletNotFound,
+			expCode: CodeWalletNotFound,
+			expStat: http.StatusNotFound,
+		},
+		{
+			name:    "wrapped_wallet_error",
+			status:  http.StatusBadRequest,
+			err:     errors.Wrap(wallet.ErrLabelAlreadyExists, "failed to rename wallet"),
+			expCode: CodeLabelExists,
+			expStat: http.StatusConflict,
+		},
+		{
+			name:   "value_not_in_range",
+			status: http.StatusInternalServerError,
+			err: wallet.ErrValueNotInRange{
+				ValName: "aCount",
+				ExpMin:  3,
+				ExpMax:  10,
+				Got:     11,
+			},
+			expCode: CodeValueNotInRange,
+			expStat: http.StatusBadRequest,
+			expDet:  RangeDetails{Name: "aCount", Min: &three, Max: &ten, Got: 11},
+		},
+		{
+			name:    "mnemonic_error",
+			status:  http.StatusInternalServerError,
+			err:     &wallet.MnemonicError{Reason: wallet.MnemonicUnknownWord, Word: "usefull", Index: 7},
+			expCode: CodeInvalidSeed,
+			expStat: http.StatusBadRequest,
+			expDet:  SeedDetails{Reason: wallet.MnemonicUnknownWord, Word: "usefull", Position: 8},
+		},
+		{
+			name:    "missing_field",
+			status:  http.StatusInternalServerError,
+			err:     errMissing("label"),
+			expCode: CodeInvalidRequest,
+			expStat: http.StatusBadRequest,
+			expDet:  FieldDetails{Field: "label"},
+		},
+		{
+			name:    "option_error",
+			status:  http.StatusInternalServerError,
+			err:     wallet.ErrMissingPassword,
+			expCode: CodeInvalidPassword,
+			expStat: http.StatusBadRequest,
+		},
+		{
+			name:    "other_error",
+			status:  http.StatusInternalServerError,
+			err:     errors.New("disk is full"),
+			expCode: ErrorCode("internal_server_error"),
+			expStat: http.StatusInternalServerError,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			require.NoError(t, sendError(w, c.status, c.err))
+			require.Equal(t, c.expStat, w.Code)
+
+			var reply struct {
+				Error struct {
+					Code    ErrorCode       `json:"code"`
+					Message string          `json:"message"`
+					Details json.RawMessage `json:"details"`
+				} `json:"error"`
+			}
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&reply))
+			require.Equal(t, c.expCode, reply.Error.Code)
+			require.Equal(t, c.err.Error(), reply.Error.Message)
+			if c.expDet == nil {
+				require.Empty(t, reply.Error.Details)
+				return
+			}
+			exp, err := json.Marshal(c.expDet)
+			require.NoError(t, err)
+			require.JSONEq(t, string(exp), string(reply.Error.Details))
+		})
+	}
+}
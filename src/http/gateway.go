@@ -3,6 +3,7 @@ package http
 import (
 	"encoding/json"
 	"fmt"
+	"mime"
 	"net/http"
 	"strings"
 
@@ -18,7 +19,7 @@ type Gateway struct {
 }
 
 func (g *Gateway) host(mux *http.ServeMux) error {
-	if err := toolsGateway(mux); err != nil {
+	if err := toolsGateway(mux, g.Wallet); err != nil {
 		return err
 	}
 	if g.Proxy != nil {
@@ -27,7 +28,15 @@ func (g *Gateway) host(mux *http.ServeMux) error {
 		}
 	}
 	if g.Wallet != nil {
-		if err := walletGateway(mux, g.Wallet); err != nil {
+		// Restoring wallets discovers used addresses through the proxy.
+		var checker wallet.AddressChecker
+		if g.Proxy != nil {
+			checker = g.Proxy
+		}
+		if err := walletGateway(mux, g.Wallet, checker); err != nil {
+			return err
+		}
+		if err := eventsGateway(mux, g.Wallet); err != nil {
 			return err
 		}
 	}
@@ -54,7 +63,7 @@ func Handle(mux *http.ServeMux, pattern, method string, handler HandlerFunc) {
 			err := errors.Errorf("invalid method type of '%s', expected '%s'",
 				r.Method, method)
 
-			sendJson(w, http.StatusBadRequest, err.Error())
+			sendError(w, http.StatusMethodNotAllowed, err)
 			logPrefix("ERROR: ", err.Error())
 
 		} else if err := handler(w, r, NewPath(r)); err != nil {
@@ -80,12 +89,17 @@ const (
 
 type ContTypeActions map[ContTypeVal]func() (bool, error)
 
+// SwitchContType calls the action of the request's 'Content-Type', of which
+// parameters such as the charset are ignored.
 func SwitchContType(w http.ResponseWriter, r *http.Request, m ContTypeActions) (bool, error) {
 	v := ContTypeVal(r.Header.Get(ContTypeKey))
+	if mt, _, e := mime.ParseMediaType(string(v)); e == nil {
+		v = ContTypeVal(mt)
+	}
 	action, ok := m[v]
 	if !ok {
-		return false, sendJson(w, http.StatusBadRequest,
-			fmt.Sprintf("invalid '%s' query of '%s'", ReqQueryKey, v))
+		return false, sendError(w, http.StatusUnsupportedMediaType,
+			errors.Errorf("unsupported content type '%s'", v))
 	}
 	return action()
 }
@@ -111,8 +125,8 @@ func SwitchReqQuery(w http.ResponseWriter, r *http.Request, defVal ReqQueryVal,
 	}
 	action, ok := m[v]
 	if !ok {
-		return false, sendJson(w, http.StatusBadRequest,
-			fmt.Sprintf("invalid '%s' query of '%s'", ReqQueryKey, v))
+		return false, sendError(w, http.StatusBadRequest,
+			errors.Errorf("invalid '%s' query of '%s'", ReqQueryKey, v))
 	}
 	return action()
 }
@@ -138,8 +152,8 @@ func SwitchTypeQuery(w http.ResponseWriter, r *http.Request, defVal TypeQueryVal
 	}
 	action, ok := m[v]
 	if !ok {
-		return sendJson(w, http.StatusBadRequest,
-			fmt.Sprintf("invalid '%s' query of '%s'", TypeQueryKey, v))
+		return sendError(w, http.StatusBadRequest,
+			errors.Errorf("invalid '%s' query of '%s'", TypeQueryKey, v))
 	}
 	return action()
 }
@@ -167,6 +181,17 @@ func sendBin(w http.ResponseWriter, status int, data []byte) error {
 	return e
 }
 
+// splitList splits a comma separated form value, ignoring empty items.
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
 /*
 	<<< TransformURL Handler >>>
 */
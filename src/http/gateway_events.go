@@ -0,0 +1,72 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/watercompany/kittycash-wallet/src/wallet"
+)
+
+const (
+	// eventsKeepAlive is the interval at which comments are sent on idle
+	// event streams, so that proxies and clients keep them open.
+	eventsKeepAlive = 15 * time.Second
+)
+
+func eventsGateway(m *http.ServeMux, g *wallet.Manager) error {
+	Handle(m, "/v1/events", "GET", streamEvents(g))
+	return nil
+}
+
+// streamEvents sends the events of the wallet manager as Server-Sent Events,
+// until the client disconnects. Each event is named by it's type, has it's
+// seq as id, and it's json encoding as data.
+func streamEvents(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			return sendError(w, http.StatusInternalServerError,
+				errors.New("streaming is not supported"))
+		}
+		events, cancel := g.Subscribe()
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		ticker := time.NewTicker(eventsKeepAlive)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return nil
+			case <-ticker.C:
+				if _, e := fmt.Fprint(w, ": keep-alive\n\n"); e != nil {
+					return e
+				}
+			case event, ok := <-events:
+				if !ok {
+					// The wallet manager is closed.
+					return nil
+				}
+				data, e := json.Marshal(event)
+				if e != nil {
+					return e
+				}
+				if _, e := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n",
+					event.Seq, event.Type, data); e != nil {
+					return e
+				}
+			}
+			flusher.Flush()
+		}
+	}
+}
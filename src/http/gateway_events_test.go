@@ -0,0 +1,73 @@
+package http
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/watercompany/kittycash-wallet/src/wallet"
+)
+
+func TestEventsGateway(t *testing.T) {
+	manager, err := wallet.NewManager(&wallet.ManagerConfig{
+		Storage: wallet.NewMemoryStorage(),
+	})
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	require.NoError(t, eventsGateway(mux, manager))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/v1/events")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	require.NoError(t, manager.NewWallet(&wallet.Options{
+		Label: "wallet0",
+		Seed:  testSeed,
+	}, 1))
+	require.NoError(t, manager.RenameWallet("wallet0", "wallet1"))
+
+	// readEvent reads the fields of the next event of the stream.
+	body := bufio.NewReader(resp.Body)
+	readEvent := func() map[string]string {
+		fields := make(map[string]string)
+		for {
+			line, err := body.ReadString('\n')
+			require.NoError(t, err)
+			if line = strings.TrimSuffix(line, "\n"); line == "" {
+				return fields
+			}
+			kv := strings.SplitN(line, ": ", 2)
+			require.Len(t, kv, 2)
+			fields[kv[0]] = kv[1]
+		}
+	}
+
+	fields := readEvent()
+	require.Equal(t, "1", fields["id"])
+	require.Equal(t, "created", fields["event"])
+	var event wallet.Event
+	require.NoError(t, json.Unmarshal([]byte(fields["data"]), &event))
+	require.Equal(t, wallet.EventCreated, event.Type)
+	require.Equal(t, "wallet0", event.Label)
+
+	fields = readEvent()
+	require.Equal(t, "renamed", fields["event"])
+	require.NoError(t, json.Unmarshal([]byte(fields["data"]), &event))
+	require.Equal(t, "wallet1", event.Label)
+	require.Equal(t, "wallet0", event.From)
+
+	// The stream ends when the manager is closed.
+	manager.Close()
+	_, err = body.ReadString('\n')
+	require.Error(t, err)
+}
@@ -1,51 +1,69 @@
 package http
 
 import (
-	"fmt"
 	"net/http"
-	"strconv"
+
+	"github.com/pkg/errors"
 
 	"github.com/watercompany/kittycash-wallet/src/tools"
+	"github.com/watercompany/kittycash-wallet/src/wallet"
 )
 
-func toolsGateway(m *http.ServeMux) error {
-	Handle(m, "/v1/tools/sign_transfer_params", "POST", signTransferParams())
+func toolsGateway(m *http.ServeMux, g *wallet.Manager) error {
+	Handle(m, "/v1/tools/sign_transfer_params", "POST", signTransferParams(g))
 	return nil
 }
 
-func signTransferParams() HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+// SignTransferParamsRequest is the request of
+// '/v1/tools/sign_transfer_params'. The transfer is signed with either the
+// secret key, or the key of the entry of address in the wallet of label.
+type SignTransferParamsRequest struct {
+	KittyID         *uint64 `json:"kittyID"`
+	LastTransferSig string  `json:"lastTransferSig"`
+	ToAddress       string  `json:"toAddress"`
+	SecretKey       string  `json:"secretKey"` // Optional if label is given.
+	Label           string  `json:"label"`     // Optional.
+	Password        string  `json:"password"`  // Optional.
+	Address         string  `json:"address"`   // Required if label is given.
+}
 
-		// Only allow 'Content-Type' of 'application/x-www-form-urlencoded'.
-		_, err := SwitchContType(w, r, ContTypeActions{
-			CtApplicationForm: func() (bool, error) {
-				var (
-					vKittyID         = r.PostFormValue("kittyID")
-					vLastTransferSig = r.PostFormValue("lastTransferSig")
-					vToAddress       = r.PostFormValue("toAddress")
-					vSecretKey       = r.PostFormValue("secretKey")
-				)
+// Process implements Processor.
+func (req *SignTransferParamsRequest) Process() error {
+	if req.KittyID == nil {
+		return errMissing("kittyID")
+	}
+	if req.Label != "" && req.Address == "" {
+		return errMissing("address")
+	}
+	return nil
+}
 
-				kittyID, err := strconv.ParseUint(vKittyID, 10, 64)
-				if err != nil {
-					return false, sendJson(w, http.StatusBadRequest,
-						fmt.Sprintf("Error: %s", err))
+func signTransferParams(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var req SignTransferParamsRequest
+		_, err := SwitchRequest(w, r, &req, func() (bool, error) {
+			if req.Label != "" {
+				if g == nil {
+					return false, sendError(w, http.StatusBadRequest,
+						errors.New("signing with a wallet requires the wallet manager"))
 				}
-
-				out, err := tools.SignTransferParams(r.Context(), &tools.SignTransferParamsIn{
-					KittyID:         kittyID,
-					LastTransferSig: vLastTransferSig,
-					ToAddress:       vToAddress,
-					SecretKey:       vSecretKey,
-				})
-
+				// Watch-only wallets are refused with wallet.ErrWatchOnly.
+				sk, err := g.SecKey(req.Label, req.Password, req.Address)
 				if err != nil {
-					return false, sendJson(w, http.StatusBadRequest,
-						fmt.Sprintf("Error: %s", err))
+					return false, sendError(w, http.StatusBadRequest, err)
 				}
-
-				return true, sendJson(w, http.StatusOK, out)
-			},
+				req.SecretKey = sk.Hex()
+			}
+			out, err := tools.SignTransferParams(r.Context(), &tools.SignTransferParamsIn{
+				KittyID:         *req.KittyID,
+				LastTransferSig: req.LastTransferSig,
+				ToAddress:       req.ToAddress,
+				SecretKey:       req.SecretKey,
+			})
+			if err != nil {
+				return false, sendError(w, http.StatusBadRequest, err)
+			}
+			return true, sendJson(w, http.StatusOK, out)
 		})
 		return err
 	}
@@ -0,0 +1,63 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/stretchr/testify/require"
+
+	"github.com/watercompany/kittycash-wallet/src/wallet"
+)
+
+func TestSignTransferParams(t *testing.T) {
+	manager, err := wallet.NewManager(&wallet.ManagerConfig{
+		Storage: wallet.NewMemoryStorage(),
+	})
+	require.NoError(t, err)
+	defer manager.Close()
+
+	require.NoError(t, manager.NewWallet(&wallet.Options{
+		Label: "wallet0",
+		Seed:  testSeed,
+	}, 1))
+	fw, err := manager.DisplayWallet("wallet0", "", 0)
+	require.NoError(t, err)
+	address := fw.Entries[0].Address
+
+	entries, err := wallet.NewWatchEntries([]string{address})
+	require.NoError(t, err)
+	require.NoError(t, manager.NewWatchOnlyWallet(&wallet.Options{
+		Label:     "watched",
+		WatchOnly: true,
+	}, entries))
+
+	mux := http.NewServeMux()
+	require.NoError(t, toolsGateway(mux, manager))
+
+	pk, _ := cipher.GenerateKeyPair()
+	sign := func(values url.Values) *httptest.ResponseRecorder {
+		values.Set("kittyID", "1")
+		values.Set("toAddress", cipher.AddressFromPubKey(pk).String())
+		r := httptest.NewRequest(http.MethodPost, "/v1/tools/sign_transfer_params",
+			strings.NewReader(values.Encode()))
+		r.Header = CTApplicationFormHeaders
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	w := sign(url.Values{"label": {"wallet0"}, "address": {address}})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = sign(url.Values{"label": {"watched"}, "address": {address}})
+	require.Equal(t, http.StatusConflict, w.Code)
+	errorChecker(CodeWatchOnly)(t, w.Result())
+
+	w = sign(url.Values{"label": {"wallet0"}})
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	errorChecker(CodeInvalidRequest)(t, w.Result())
+}
@@ -1,21 +1,43 @@
 package http
 
 import (
-	"fmt"
+	"encoding/json"
 	"net/http"
-	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
 
 	"github.com/watercompany/kittycash-wallet/src/wallet"
 )
 
-func walletGateway(m *http.ServeMux, g *wallet.Manager) error {
+func walletGateway(m *http.ServeMux, g *wallet.Manager, c wallet.AddressChecker) error {
 	Handle(m, "/v1/wallets/refresh", "GET", refreshWallets(g))
 	Handle(m, "/v1/wallets/list", "GET", listWallets(g))
+	Handle(m, "/v1/wallets/diagnostics", "GET", diagnoseWallets(g))
+	Handle(m, "/v1/wallets/changes", "POST", listChanges(g))
 	Handle(m, "/v1/wallets/new", "POST", newWallet(g))
+	Handle(m, "/v1/wallets/restore", "POST", restoreWallet(g, c))
+	Handle(m, "/v1/wallets/new_watch_only", "POST", newWatchOnlyWallet(g))
+	Handle(m, "/v1/wallets/add_watch_entries", "POST", addWatchEntries(g))
+	Handle(m, "/v1/wallets/import_key", "POST", importKey(g))
+	Handle(m, "/v1/wallets/entries/update", "POST", updateEntry(g))
 	Handle(m, "/v1/wallets/delete", "POST", deleteWallet(g))
 	Handle(m, "/v1/wallets/get", "POST", getWallet(g))
 	Handle(m, "/v1/wallets/get_paginated", "POST", getWalletPaginated(g))
+	Handle(m, "/v1/wallets/export_secrets", "POST", exportSecrets(g))
+	Handle(m, "/v1/wallets/xpub", "POST", exportXPub(g))
+	Handle(m, "/v1/wallets/accounts/new", "POST", newAccount(g))
+	Handle(m, "/v1/wallets/accounts/rename", "POST", renameAccount(g))
+	Handle(m, "/v1/wallets/accounts/list", "POST", listAccounts(g))
+	Handle(m, "/v1/wallets/export", "POST", exportWallet(g))
+	Handle(m, "/v1/wallets/import", "POST", importWallet(g))
 	Handle(m, "/v1/wallets/rename", "POST", renameWallet(g))
+	Handle(m, "/v1/wallets/backups/list", "POST", listBackups(g))
+	Handle(m, "/v1/wallets/backups/restore", "POST", restoreBackup(g))
+	Handle(m, "/v1/wallets/change_password", "POST", changePassword(g))
+	Handle(m, "/v1/wallets/set_encryption", "POST", setEncryption(g))
+	Handle(m, "/v1/wallets/lock", "POST", lockWallet(g))
+	Handle(m, "/v1/wallets/unlock", "POST", unlockWallet(g))
 	Handle(m, "/v1/wallets/seed", "POST", newSeed())
 	return nil
 }
@@ -24,8 +46,7 @@ func refreshWallets(g *wallet.Manager) HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
 		// Send json response with 500 status code if error.
 		if e := g.Refresh(); e != nil {
-			return sendJson(w, http.StatusInternalServerError,
-				fmt.Sprintf("message: '%s'", e))
+			return sendError(w, http.StatusInternalServerError, e)
 		}
 		// Send json response with 200 status code if error is nil.
 		return sendJson(w, http.StatusOK, true)
@@ -44,238 +65,715 @@ func listWallets(g *wallet.Manager) HandlerFunc {
 	}
 }
 
+type DiagnosticsReply struct {
+	Files []wallet.Diagnostic `json:"files"`
+}
+
+func diagnoseWallets(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		return sendJson(w, http.StatusOK, DiagnosticsReply{
+			Files: g.Diagnose(),
+		})
+	}
+}
+
+// WalletRequest is the request of endpoints that act on a wallet, of which
+// the password is needed if it is still locked.
+type WalletRequest struct {
+	Label    string `json:"label"`
+	Password string `json:"password"` // Optional.
+}
+
+// LabelRequest is the request of endpoints that act on the file of a wallet.
+type LabelRequest struct {
+	Label string `json:"label"`
+}
+
+// ChangesRequest is the request of '/v1/wallets/changes'.
+type ChangesRequest struct {
+	Since uint64 `json:"since"` // Optional.
+}
+
+type ChangesReply struct {
+	Changes []wallet.Change `json:"changes"`
+	Seq     uint64          `json:"seq"`
+}
+
+func listChanges(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var req ChangesRequest
+		_, e := SwitchRequest(w, r, &req, func() (bool, error) {
+			changes, seq := g.Changes(req.Since)
+			return true, sendJson(w, http.StatusOK, ChangesReply{
+				Changes: changes,
+				Seq:     seq,
+			})
+		})
+		return e
+	}
+}
+
+// SeedOptions are the options of wallets created from a seed.
+type SeedOptions struct {
+	Label        string            `json:"label"`
+	Seed         string            `json:"seed"`
+	AllowRawSeed bool              `json:"allowRawSeed"` // Optional.
+	Passphrase   string            `json:"passphrase"`   // Optional.
+	Derivation   wallet.Derivation `json:"derivation"`   // Optional.
+	Encrypted    *bool             `json:"encrypted"`
+	Password     string            `json:"password"`
+}
+
+// Options obtains the wallet options.
+func (o *SeedOptions) Options() *wallet.Options {
+	return &wallet.Options{
+		Label:        o.Label,
+		Seed:         o.Seed,
+		Encrypted:    o.Encrypted != nil && *o.Encrypted,
+		Password:     o.Password,
+		AllowRawSeed: o.AllowRawSeed,
+		Passphrase:   o.Passphrase,
+		Derivation:   o.Derivation,
+	}
+}
+
+// Process implements Processor.
+func (o *SeedOptions) Process() error {
+	if o.Encrypted == nil {
+		return errMissing("encrypted")
+	}
+	return o.Options().Verify()
+}
+
+// NewWalletRequest is the request of '/v1/wallets/new'.
+type NewWalletRequest struct {
+	SeedOptions
+	Addresses *int `json:"aCount"`
+}
+
+// Process implements Processor.
+func (req *NewWalletRequest) Process() error {
+	if req.Addresses == nil {
+		return errMissing("aCount")
+	}
+	if e := verifyCount("aCount", *req.Addresses); e != nil {
+		return e
+	}
+	return req.SeedOptions.Process()
+}
+
+// verifyCount checks that a count of entries to generate is not negative.
+func verifyCount(name string, n int) error {
+	if n < 0 {
+		return wallet.ErrValueNotInRange{
+			ValName: name,
+			HasMin:  true,
+			ExpMin:  0,
+			Got:     n,
+		}
+	}
+	return nil
+}
+
 func newWallet(g *wallet.Manager) HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var req NewWalletRequest
+		_, e := SwitchRequest(w, r, &req, func() (bool, error) {
+			if e := g.NewWallet(req.Options(), *req.Addresses); e != nil {
+				return false, sendError(w, http.StatusInternalServerError, e)
+			}
+			return true, sendJson(w, http.StatusOK, true)
+		})
+		return e
+	}
+}
 
-		// Only allow 'Content-Type' of 'application/x-www-form-urlencoded'.
-		_, e := SwitchContType(w, r, ContTypeActions{
-			CtApplicationForm: func() (bool, error) {
-				var (
-					vLabel     = r.PostFormValue("label")
-					vSeed      = r.PostFormValue("seed")
-					vAddresses = r.PostFormValue("aCount")
-					vEncrypted = r.PostFormValue("encrypted")
-					vPassword  = r.PostFormValue("password")
-				)
-
-				encrypted, e := strconv.ParseBool(vEncrypted)
-				if e != nil {
-					return false, sendJson(w, http.StatusBadRequest,
-						fmt.Sprintf("Error: %s", e))
-				}
+// RestoreWalletRequest is the request of '/v1/wallets/restore'.
+type RestoreWalletRequest struct {
+	SeedOptions
+	GapLimit int `json:"gapLimit"` // Optional.
+}
 
-				// Options to pass to g.NewWallet()
-				opts := wallet.Options{
-					Label:     vLabel,
-					Seed:      vSeed,
-					Encrypted: encrypted,
-					Password:  vPassword,
-				}
+// Process implements Processor.
+func (req *RestoreWalletRequest) Process() error {
+	if req.GapLimit == 0 {
+		req.GapLimit = wallet.DefaultGapLimit
+	}
+	return req.SeedOptions.Process()
+}
 
-				/**
-				 * Verify that all values are correct
-				 * Respond if options are not correct
-				 */
+type RestoreReply struct {
+	Label      string `json:"label"`
+	EntryCount int    `json:"entry_count"`
+}
 
-				if e := opts.Verify(); e != nil {
-					return false, sendJson(w, http.StatusBadRequest,
-						fmt.Sprintf("Error: %s", e.Error()))
-				}
+func restoreWallet(g *wallet.Manager, c wallet.AddressChecker) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var req RestoreWalletRequest
+		_, e := SwitchRequest(w, r, &req, func() (bool, error) {
+			count, e := g.RestoreWallet(req.Options(), req.GapLimit, c)
+			if e != nil {
+				return false, sendError(w, http.StatusInternalServerError, e)
+			}
+			return true, sendJson(w, http.StatusOK, RestoreReply{
+				Label:      req.Label,
+				EntryCount: count,
+			})
+		})
+		return e
+	}
+}
 
-				// Get aCount and convert it to int.
-				aCount, e := strconv.Atoi(vAddresses)
-				if e != nil {
-					return false, sendJson(w, http.StatusNotAcceptable,
-						fmt.Sprintf("Error: %s", e.Error()))
-				}
+// NewWatchOnlyWalletRequest is the request of '/v1/wallets/new_watch_only'.
+// Addresses are comma separated if form encoded.
+type NewWatchOnlyWalletRequest struct {
+	Label     string   `json:"label"`
+	Addresses []string `json:"addresses"` // Optional.
+	Encrypted *bool    `json:"encrypted"`
+	Password  string   `json:"password"`
+}
 
-				if e := g.NewWallet(&opts, aCount); e != nil {
-					return false, sendJson(w, http.StatusInternalServerError,
-						fmt.Sprintf("Error: %s", e.Error()))
-				}
+// Options obtains the wallet options.
+func (req *NewWatchOnlyWalletRequest) Options() *wallet.Options {
+	return &wallet.Options{
+		Label:     req.Label,
+		WatchOnly: true,
+		Encrypted: req.Encrypted != nil && *req.Encrypted,
+		Password:  req.Password,
+	}
+}
 
-				return true, sendJson(w, http.StatusOK, true)
-			},
+// Process implements Processor.
+func (req *NewWatchOnlyWalletRequest) Process() error {
+	if req.Encrypted == nil {
+		return errMissing("encrypted")
+	}
+	return req.Options().Verify()
+}
+
+func newWatchOnlyWallet(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var req NewWatchOnlyWalletRequest
+		_, e := SwitchRequest(w, r, &req, func() (bool, error) {
+			entries, e := wallet.NewWatchEntries(req.Addresses)
+			if e != nil {
+				return false, sendError(w, http.StatusBadRequest, e)
+			}
+			if e := g.NewWatchOnlyWallet(req.Options(), entries); e != nil {
+				return false, sendError(w, http.StatusInternalServerError, e)
+			}
+			return true, sendJson(w, http.StatusOK, true)
 		})
 		return e
 	}
 }
 
-func deleteWallet(g *wallet.Manager) HandlerFunc {
+// AddWatchEntriesRequest is the request of '/v1/wallets/add_watch_entries'.
+// Addresses are comma separated if form encoded.
+type AddWatchEntriesRequest struct {
+	WalletRequest
+	Addresses []string `json:"addresses"`
+}
+
+func addWatchEntries(g *wallet.Manager) HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var req AddWatchEntriesRequest
+		_, e := SwitchRequest(w, r, &req, func() (bool, error) {
+			entries, e := wallet.NewWatchEntries(req.Addresses)
+			if e != nil {
+				return false, sendError(w, http.StatusBadRequest, e)
+			}
+			if e := g.AddWatchEntries(req.Label, req.Password, entries); e != nil {
+				return false, sendError(w, http.StatusBadRequest, e)
+			}
+			return true, sendJson(w, http.StatusOK, true)
+		})
+		return e
+	}
+}
 
-		// Only allow 'Content-Type' of 'application/x-www-form-urlencoded'.
-		_, e := SwitchContType(w, r, ContTypeActions{
-			CtApplicationForm: func() (bool, error) {
-				var (
-					vLabel = r.PostFormValue("label")
-				)
-				if r.Body == nil {
-					return false, sendJson(w, http.StatusBadRequest,
-						fmt.Sprint("request body missing"))
-				}
-				if e := g.DeleteWallet(vLabel); e != nil {
-					return false, sendJson(w, http.StatusBadRequest,
-						fmt.Sprintf("Error: failed to delete wallet of label '%s': %v",
-							vLabel, e))
-				}
-				return true, sendJson(w, http.StatusOK, true)
-			},
+// ImportKeyRequest is the request of '/v1/wallets/import_key'.
+type ImportKeyRequest struct {
+	WalletRequest
+	SecretKey string `json:"secretKey"`
+}
+
+func importKey(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var req ImportKeyRequest
+		_, e := SwitchRequest(w, r, &req, func() (bool, error) {
+			if e := g.ImportKey(req.Label, req.Password, req.SecretKey); e != nil {
+				return false, sendError(w, http.StatusBadRequest, e)
+			}
+			return true, sendJson(w, http.StatusOK, true)
 		})
 		return e
 	}
 }
 
-func getWallet(g *wallet.Manager) HandlerFunc {
+// UpdateEntryRequest is the request of '/v1/wallets/entries/update'. Fields
+// of the entry that are not given are left unchanged.
+type UpdateEntryRequest struct {
+	WalletRequest
+	Address    string  `json:"address"`
+	EntryLabel *string `json:"entryLabel"` // Optional.
+	Note       *string `json:"note"`       // Optional.
+	Hidden     *bool   `json:"hidden"`     // Optional.
+}
+
+func updateEntry(g *wallet.Manager) HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var req UpdateEntryRequest
+		_, e := SwitchRequest(w, r, &req, func() (bool, error) {
+			fe, e := g.UpdateEntry(req.Label, req.Password, req.Address, &wallet.EntryUpdate{
+				Label:  req.EntryLabel,
+				Note:   req.Note,
+				Hidden: req.Hidden,
+			})
+			if e != nil {
+				return false, sendError(w, http.StatusBadRequest, e)
+			}
+			return true, sendJson(w, http.StatusOK, fe)
+		})
+		return e
+	}
+}
 
-		// Only allow 'Content-Type' of 'application/x-www-form-urlencoded'.
-		_, e := SwitchContType(w, r, ContTypeActions{
-			CtApplicationForm: func() (bool, error) {
-				var (
-					vLabel     = r.PostFormValue("label")
-					vPassword  = r.PostFormValue("password") // Optional.
-					vAddresses = r.PostFormValue("aCount")   // Optional.
-				)
-				if r.Body == nil {
-					return false, sendJson(w, http.StatusBadRequest,
-						fmt.Sprint("request body missing"))
-				}
-				var addresses int
-				if vAddresses != "" {
-					var e error
-					addresses, e = strconv.Atoi(vAddresses)
-					if e != nil {
-						return false, sendJson(w, http.StatusBadRequest,
-							fmt.Sprintf("Error: %s", e))
-					}
-				}
-				fw, e := g.DisplayWallet(vLabel, vPassword, addresses)
-				if e != nil {
-					return false, sendJson(w, http.StatusBadRequest,
-						fmt.Sprintf("Error: %v", e))
-				} else if fw == nil {
-					// WORKAROUND: happens only on panic within DisplayWallet function.
-					// 		panic should only happen when wrong password is given.
-					return false, sendJson(w, http.StatusBadRequest,
-						fmt.Sprintf("Error: %v", wallet.ErrInvalidPassword))
-				}
-				return true, sendJson(w, http.StatusOK, fw)
-			},
+func deleteWallet(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var req LabelRequest
+		_, e := SwitchRequest(w, r, &req, func() (bool, error) {
+			if e := g.DeleteWallet(req.Label); e != nil {
+				return false, sendError(w, http.StatusBadRequest,
+					errors.Wrapf(e, "failed to delete wallet of label '%s'", req.Label))
+			}
+			return true, sendJson(w, http.StatusOK, true)
 		})
 		return e
 	}
 }
 
+// GetWalletRequest is the request of '/v1/wallets/get'.
+type GetWalletRequest struct {
+	WalletRequest
+	Addresses int `json:"aCount"` // Optional.
+}
+
+// Process implements Processor.
+func (req *GetWalletRequest) Process() error {
+	return verifyCount("aCount", req.Addresses)
+}
+
+func getWallet(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var req GetWalletRequest
+		_, e := SwitchRequest(w, r, &req, func() (bool, error) {
+			fw, e := g.DisplayWallet(req.Label, req.Password, req.Addresses)
+			if e != nil {
+				return false, sendError(w, http.StatusBadRequest, e)
+			}
+			return true, sendJson(w, http.StatusOK, fw)
+		})
+		return e
+	}
+}
+
+// GetWalletPaginatedRequest is the request of '/v1/wallets/get_paginated'.
+// If ForceTotal is given, the account is ensured to have that many entries.
+type GetWalletPaginatedRequest struct {
+	WalletRequest
+	Account    uint32 `json:"account"` // Optional.
+	StartIndex int    `json:"startIndex"`
+	PageSize   int    `json:"pageSize"`
+	ForceTotal *int   `json:"forceTotal"` // Optional.
+}
+
 func getWalletPaginated(g *wallet.Manager) HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var req GetWalletPaginatedRequest
+		_, err := SwitchRequest(w, r, &req, func() (bool, error) {
+			forceTotal := -1
+			if req.ForceTotal != nil {
+				forceTotal = *req.ForceTotal
+			}
+			fw, err := g.DisplayPaginatedWallet(req.Label, req.Password, req.Account,
+				req.StartIndex, req.PageSize, forceTotal)
+			if err != nil {
+				return false, sendError(w, http.StatusBadRequest, err)
+			}
+			return true, sendJson(w, http.StatusOK, fw)
+		})
+		return err
+	}
+}
 
-		// Only allow 'Content-Type' of 'application/x-www-form-urlencoded'.
-		_, err := SwitchContType(w, r, ContTypeActions{
-			CtApplicationForm: func() (bool, error) {
-				var (
-					vLabel      = r.PostFormValue("label")
-					vPassword   = r.PostFormValue("password") // Optional.
-					vStartIndex = r.PostFormValue("startIndex")
-					vPageSize   = r.PostFormValue("pageSize")
-					vForceTotal = r.PostFormValue("forceTotal")
-				)
-				if r.Body == nil {
-					return false, sendJson(w, http.StatusBadRequest,
-						fmt.Sprint("request body missing"))
-				}
-				var startIndex int
-				if vStartIndex != "" {
-					var err error
-					if startIndex, err = strconv.Atoi(vStartIndex); err != nil {
-						return false, sendJson(w, http.StatusBadRequest,
-							fmt.Sprintf("invalid startIndex: %s", err.Error()))
-					}
-				}
-				var pageSize int
-				if vPageSize != "" {
-					var err error
-					if pageSize, err = strconv.Atoi(vPageSize); err != nil {
-						return false, sendJson(w, http.StatusBadRequest,
-							fmt.Sprintf("invalid pageSize: %s", err.Error()))
-					}
-				}
-				var forceTotal int
-				if vForceTotal != "" {
-					var err error
-					if forceTotal, err = strconv.Atoi(vForceTotal); err != nil {
-						return false, sendJson(w, http.StatusBadRequest,
-							fmt.Sprintf("invalid forceTotal: %s", err.Error()))
-					}
-				} else {
-					forceTotal = -1
-				}
+// exportSecrets expects a WalletRequest, of which the password is required
+// if the wallet is encrypted.
+func exportSecrets(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var req WalletRequest
+		_, e := SwitchRequest(w, r, &req, func() (bool, error) {
+			fw, e := g.ExportSecrets(req.Label, req.Password)
+			if e != nil {
+				return false, sendError(w, http.StatusBadRequest, e)
+			}
+			return true, sendJson(w, http.StatusOK, fw)
+		})
+		return e
+	}
+}
 
-				fw, err := g.DisplayPaginatedWallet(vLabel, vPassword, startIndex, pageSize, forceTotal)
-				if err != nil {
-					return false, sendJson(w, http.StatusBadRequest,
-						fmt.Sprintf("Error: %v", err))
-				} else if fw == nil {
-					// WORKAROUND: happens only on panic within DisplayWallet function.
-					// 		panic should only happen when wrong password is given.
-					return false, sendJson(w, http.StatusBadRequest,
-						fmt.Sprintf("Error: %v", wallet.ErrInvalidPassword))
+// AccountRequest is the request of endpoints that act on an account of a
+// wallet.
+type AccountRequest struct {
+	WalletRequest
+	Account uint32 `json:"account"` // Optional.
+}
+
+type XPubReply struct {
+	XPub string `json:"xpub"`
+	Path string `json:"path"`
+}
+
+func exportXPub(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var req AccountRequest
+		_, e := SwitchRequest(w, r, &req, func() (bool, error) {
+			xpub, path, e := g.ExportXPub(req.Label, req.Password, req.Account)
+			if e != nil {
+				return false, sendError(w, http.StatusBadRequest, e)
+			}
+			return true, sendJson(w, http.StatusOK, XPubReply{
+				XPub: xpub,
+				Path: path,
+			})
+		})
+		return e
+	}
+}
+
+// NewAccountRequest is the request of '/v1/wallets/accounts/new'.
+type NewAccountRequest struct {
+	WalletRequest
+	Name      string `json:"name"`
+	Addresses int    `json:"aCount"` // Optional.
+}
+
+// Process implements Processor.
+func (req *NewAccountRequest) Process() error {
+	return verifyCount("aCount", req.Addresses)
+}
+
+type AccountsReply struct {
+	Accounts []wallet.AccountStat `json:"accounts"`
+}
+
+func newAccount(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var req NewAccountRequest
+		_, e := SwitchRequest(w, r, &req, func() (bool, error) {
+			stat, e := g.NewAccount(req.Label, req.Password, req.Name, req.Addresses)
+			if e != nil {
+				return false, sendError(w, http.StatusBadRequest, e)
+			}
+			return true, sendJson(w, http.StatusOK, stat)
+		})
+		return e
+	}
+}
+
+// RenameAccountRequest is the request of '/v1/wallets/accounts/rename'.
+type RenameAccountRequest struct {
+	WalletRequest
+	Account *uint32 `json:"account"`
+	Name    string  `json:"name"`
+}
+
+// Process implements Processor.
+func (req *RenameAccountRequest) Process() error {
+	if req.Account == nil {
+		return errMissing("account")
+	}
+	return nil
+}
+
+func renameAccount(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var req RenameAccountRequest
+		_, e := SwitchRequest(w, r, &req, func() (bool, error) {
+			if e := g.RenameAccount(req.Label, req.Password, *req.Account, req.Name); e != nil {
+				return false, sendError(w, http.StatusBadRequest, e)
+			}
+			return true, sendJson(w, http.StatusOK, true)
+		})
+		return e
+	}
+}
+
+func listAccounts(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var req WalletRequest
+		_, e := SwitchRequest(w, r, &req, func() (bool, error) {
+			accounts, e := g.ListAccounts(req.Label, req.Password)
+			if e != nil {
+				return false, sendError(w, http.StatusBadRequest, e)
+			}
+			return true, sendJson(w, http.StatusOK, AccountsReply{
+				Accounts: accounts,
+			})
+		})
+		return e
+	}
+}
+
+// exportWallet expects a WalletRequest, of which the password is only used
+// for 'type=json'.
+func exportWallet(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var req WalletRequest
+		_, e := SwitchRequest(w, r, &req, func() (bool, error) {
+			send := func(b *wallet.Backup, e error) error {
+				if e != nil {
+					return sendError(w, http.StatusBadRequest, e)
 				}
-				return true, sendJson(w, http.StatusOK, fw)
-			},
+				return sendJson(w, http.StatusOK, b)
+			}
+
+			// 'type=enc' exports the wallet file as stored (encrypted if
+			// the wallet is encrypted), 'type=json' exports a plaintext copy.
+			return true, SwitchTypeQuery(w, r, TqEnc, TypeQueryActions{
+				TqEnc: func() error {
+					return send(g.Export(req.Label))
+				},
+				TqJson: func() error {
+					return send(g.ExportPlain(req.Label, req.Password))
+				},
+			})
 		})
-		return err
+		return e
+	}
+}
+
+// BackupText is the json text of a wallet backup. In json requests, the
+// backup can also be given as is, rather than as a string.
+type BackupText string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (b *BackupText) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, (*string)(b))
 	}
+	*b = BackupText(data)
+	return nil
+}
+
+// ImportWalletRequest is the request of '/v1/wallets/import'.
+type ImportWalletRequest struct {
+	Backup   BackupText `json:"backup"`
+	Label    string     `json:"label"`    // Optional.
+	Rename   bool       `json:"rename"`   // Optional.
+	Password string     `json:"password"` // Optional.
+}
+
+type ImportReply struct {
+	Label string `json:"label"`
+}
+
+func importWallet(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var req ImportWalletRequest
+		_, e := SwitchRequest(w, r, &req, func() (bool, error) {
+			label, e := g.Import(strings.NewReader(string(req.Backup)), &wallet.ImportOptions{
+				Label:    req.Label,
+				Rename:   req.Rename,
+				Password: req.Password,
+			})
+			if e != nil {
+				return false, sendError(w, http.StatusBadRequest, e)
+			}
+			return true, sendJson(w, http.StatusOK, ImportReply{
+				Label: label,
+			})
+		})
+		return e
+	}
+}
+
+// RenameWalletRequest is the request of '/v1/wallets/rename'.
+type RenameWalletRequest struct {
+	Label    string `json:"label"`
+	NewLabel string `json:"newLabel"`
 }
 
 func renameWallet(g *wallet.Manager) HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var req RenameWalletRequest
+		_, e := SwitchRequest(w, r, &req, func() (bool, error) {
+			if e := g.RenameWallet(req.Label, req.NewLabel); e != nil {
+				return false, sendError(w, http.StatusBadRequest, e)
+			}
+			return true, sendJson(w, http.StatusOK, true)
+		})
+		return e
+	}
+}
 
-		// Only allow 'Content-Type' of 'application/x-www-form-urlencoded'.
-		_, e := SwitchContType(w, r, ContTypeActions{
-			CtApplicationForm: func() (bool, error) {
-				var (
-					vLabel    = r.PostFormValue("label")
-					vNewLabel = r.PostFormValue("newLabel")
-				)
-
-				if e := g.RenameWallet(vLabel, vNewLabel); e != nil {
-					return false, sendJson(w, http.StatusBadRequest,
-						fmt.Sprintf("Error: %s", e.Error()))
-				}
+type BackupsReply struct {
+	Backups []wallet.FileBackup `json:"backups"`
+}
+
+func listBackups(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var req LabelRequest
+		_, e := SwitchRequest(w, r, &req, func() (bool, error) {
+			backups, e := g.ListBackups(req.Label)
+			if e != nil {
+				return false, sendError(w, http.StatusBadRequest, e)
+			}
+			return true, sendJson(w, http.StatusOK, BackupsReply{
+				Backups: backups,
+			})
+		})
+		return e
+	}
+}
 
+// RestoreBackupRequest is the request of '/v1/wallets/backups/restore'.
+type RestoreBackupRequest struct {
+	Label string `json:"label"`
+	ID    string `json:"id"`
+}
+
+func restoreBackup(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var req RestoreBackupRequest
+		_, e := SwitchRequest(w, r, &req, func() (bool, error) {
+			if e := g.RestoreBackup(req.Label, req.ID); e != nil {
+				return false, sendError(w, http.StatusBadRequest, e)
+			}
+			return true, sendJson(w, http.StatusOK, true)
+		})
+		return e
+	}
+}
+
+// ChangePasswordRequest is the request of '/v1/wallets/change_password'.
+type ChangePasswordRequest struct {
+	Label       string `json:"label"`
+	Password    string `json:"password"`
+	NewPassword string `json:"newPassword"`
+}
+
+func changePassword(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var req ChangePasswordRequest
+		_, e := SwitchRequest(w, r, &req, func() (bool, error) {
+			if e := g.ChangePassword(req.Label, req.Password, req.NewPassword); e != nil {
+				return false, sendError(w, http.StatusBadRequest, e)
+			}
+			return true, sendJson(w, http.StatusOK, true)
+		})
+		return e
+	}
+}
+
+// SetEncryptionRequest is the request of '/v1/wallets/set_encryption'.
+type SetEncryptionRequest struct {
+	Label     string `json:"label"`
+	Password  string `json:"password"`
+	Encrypted *bool  `json:"encrypted"`
+}
+
+// Process implements Processor.
+func (req *SetEncryptionRequest) Process() error {
+	if req.Encrypted == nil {
+		return errMissing("encrypted")
+	}
+	return nil
+}
+
+func setEncryption(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var req SetEncryptionRequest
+		_, e := SwitchRequest(w, r, &req, func() (bool, error) {
+			if e := g.SetEncryption(req.Label, req.Password, *req.Encrypted); e != nil {
+				return false, sendError(w, http.StatusBadRequest, e)
+			}
+			return true, sendJson(w, http.StatusOK, true)
+		})
+		return e
+	}
+}
+
+// lockWallet expects a LabelRequest, and locks all wallets if the label is
+// not specified.
+func lockWallet(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var req LabelRequest
+		_, e := SwitchRequest(w, r, &req, func() (bool, error) {
+			if req.Label == "" {
+				g.LockAll()
 				return true, sendJson(w, http.StatusOK, true)
-			},
+			}
+			if e := g.Lock(req.Label); e != nil {
+				return false, sendError(w, http.StatusBadRequest, e)
+			}
+			return true, sendJson(w, http.StatusOK, true)
+		})
+		return e
+	}
+}
+
+// UnlockWalletRequest is the request of '/v1/wallets/unlock'.
+type UnlockWalletRequest struct {
+	WalletRequest
+	Passphrase string `json:"passphrase"` // Optional.
+}
+
+func unlockWallet(g *wallet.Manager) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
+		var req UnlockWalletRequest
+		_, e := SwitchRequest(w, r, &req, func() (bool, error) {
+			if e := g.Unlock(req.Label, req.Password, req.Passphrase); e != nil {
+				return false, sendError(w, http.StatusBadRequest, e)
+			}
+			return true, sendJson(w, http.StatusOK, true)
 		})
 		return e
 	}
 }
 
+// NewSeedRequest is the request of '/v1/wallets/seed'.
+type NewSeedRequest struct {
+	SeedBitSize int `json:"seedBitSize"` // Optional.
+}
+
+// Process implements Processor.
+func (req *NewSeedRequest) Process() error {
+	if req.SeedBitSize == 0 {
+		req.SeedBitSize = wallet.DefaultSeedBitSize
+	}
+	if e := wallet.VerifySeedBitSize(req.SeedBitSize); e != nil {
+		return &fieldError{field: "seedBitSize", msg: e.Error()}
+	}
+	return nil
+}
+
 type SeedReply struct {
 	Seed string `json:"seed"`
 }
 
 func newSeed() HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request, p *Path) error {
-
-		// Only allow 'Content-Type' of 'application/x-www-form-urlencoded'.
-		_, e := SwitchContType(w, r, ContTypeActions{
-			CtApplicationForm: func() (bool, error) {
-				vSeedBitSize := r.PostFormValue("seedBitSize")
-				seedBitSize, e := wallet.SeedBitSizeFromString(vSeedBitSize)
-				if e != nil {
-					return false, sendJson(w, http.StatusBadRequest,
-						fmt.Sprintf("Error: %s", e))
-				}
-				seed, e := wallet.NewSeed(seedBitSize)
-				if e != nil {
-					return false, sendJson(w, http.StatusInternalServerError,
-						fmt.Sprintf("Error: %v", e))
-				}
-				return true, sendJson(w, http.StatusOK, SeedReply{
-					Seed: seed,
-				})
-			},
+		var req NewSeedRequest
+		_, e := SwitchRequest(w, r, &req, func() (bool, error) {
+			seed, e := wallet.NewSeed(req.SeedBitSize)
+			if e != nil {
+				return false, sendError(w, http.StatusInternalServerError, e)
+			}
+			return true, sendJson(w, http.StatusOK, SeedReply{
+				Seed: seed,
+			})
 		})
 		return e
 	}
@@ -4,10 +4,10 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
-	"os"
+	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -15,28 +15,24 @@ import (
 	"github.com/watercompany/kittycash-wallet/src/wallet"
 )
 
+// testSeed is a valid BIP39 mnemonic.
+const testSeed = "legal winner thank year wave sausage worth useful legal winner thank yellow"
+
 var CTApplicationFormHeaders = map[string][]string{"Content-Type": {"application/x-www-form-urlencoded"}}
+var CTApplicationJsonHeaders = map[string][]string{"Content-Type": {"application/json"}}
 
 type ResponseChecker func(*testing.T, *http.Response)
 
 func TestWalletGateway(t *testing.T) {
-	tempDir, err := ioutil.TempDir("", "KittyCashTestWallet")
-	require.NoError(t, err, "Created temp wallet directory")
-
-	defer func() {
-		err := os.RemoveAll(tempDir)
-		require.NoError(t, err, "Remove temp wallet directory")
-	}()
-
 	manager, err := wallet.NewManager(&wallet.ManagerConfig{
-		RootDir: tempDir,
+		Storage: wallet.NewMemoryStorage(),
 	})
 	require.NoError(t, err, "Should be able to create a wallet manager")
 
 	// Get an http server
 	mux := http.NewServeMux()
 
-	err = walletGateway(mux, manager)
+	err = walletGateway(mux, manager, nil)
 
 	require.NoError(t, err,
 		"Shouldn't have an error initializing the walletGateway")
@@ -75,8 +71,225 @@ func TestWalletGateway(t *testing.T) {
 			body:          "seedBitSize=23",
 			headers:       CTApplicationFormHeaders,
 			responseCode:  http.StatusBadRequest,
+			checkResponse: errorChecker(CodeInvalidRequest),
+		},
+		/* /v1/wallets/new tests */
+		{
+			endpoint:      "/v1/wallets/new",
+			name:          "Valid mnemonic",
+			method:        http.MethodPost,
+			body:          "label=wallet0&aCount=1&encrypted=false&seed=" + url.QueryEscape(testSeed),
+			headers:       CTApplicationFormHeaders,
+			responseCode:  http.StatusOK,
+			checkResponse: alwaysValidChecker,
+		},
+		{
+			endpoint:      "/v1/wallets/new",
+			name:          "Mistyped mnemonic word",
+			method:        http.MethodPost,
+			body:          "label=wallet1&aCount=1&encrypted=false&seed=" + url.QueryEscape(strings.Replace(testSeed, "useful", "usefull", 1)),
+			headers:       CTApplicationFormHeaders,
+			responseCode:  http.StatusBadRequest,
+			checkResponse: seedErrorChecker(wallet.MnemonicUnknownWord, "usefull", 8),
+		},
+		{
+			endpoint:      "/v1/wallets/new",
+			name:          "Mnemonic checksum mismatch",
+			method:        http.MethodPost,
+			body:          "label=wallet1&aCount=1&encrypted=false&allowRawSeed=true&seed=" + url.QueryEscape(strings.Replace(testSeed, "yellow", "year", 1)),
+			headers:       CTApplicationFormHeaders,
+			responseCode:  http.StatusBadRequest,
+			checkResponse: seedErrorChecker(wallet.MnemonicChecksum, "", 0),
+		},
+		{
+			endpoint:      "/v1/wallets/new",
+			name:          "Raw seed not allowed",
+			method:        http.MethodPost,
+			body:          "label=wallet1&aCount=1&encrypted=false&seed=secure+seed",
+			headers:       CTApplicationFormHeaders,
+			responseCode:  http.StatusBadRequest,
+			checkResponse: seedErrorChecker(wallet.MnemonicWordCount, "", 0),
+		},
+		{
+			endpoint:      "/v1/wallets/new",
+			name:          "Raw seed allowed",
+			method:        http.MethodPost,
+			body:          "label=wallet1&aCount=1&encrypted=false&allowRawSeed=true&seed=secure+seed",
+			headers:       CTApplicationFormHeaders,
+			responseCode:  http.StatusOK,
+			checkResponse: alwaysValidChecker,
+		},
+		{
+			endpoint:      "/v1/wallets/new",
+			name:          "Missing encrypted",
+			method:        http.MethodPost,
+			body:          "label=wallet2&aCount=1&seed=" + url.QueryEscape(testSeed),
+			headers:       CTApplicationFormHeaders,
+			responseCode:  http.StatusBadRequest,
+			checkResponse: errorChecker(CodeInvalidRequest),
+		},
+		/* json request bodies */
+		{
+			endpoint:      "/v1/wallets/seed",
+			name:          "No seedBitSize provided (json)",
+			method:        http.MethodPost,
+			headers:       CTApplicationJsonHeaders,
+			responseCode:  http.StatusOK,
+			checkResponse: validSeedChecker,
+		},
+		{
+			endpoint:      "/v1/wallets/seed",
+			name:          "Non-default seedBitSize provided (json)",
+			method:        http.MethodPost,
+			body:          `{"seedBitSize":256}`,
+			headers:       CTApplicationJsonHeaders,
+			responseCode:  http.StatusOK,
+			checkResponse: validSeedChecker,
+		},
+		{
+			endpoint:      "/v1/wallets/seed",
+			name:          "Invalid seedBitSize provided (json)",
+			method:        http.MethodPost,
+			body:          `{"seedBitSize":23}`,
+			headers:       CTApplicationJsonHeaders,
+			responseCode:  http.StatusBadRequest,
+			checkResponse: errorChecker(CodeInvalidRequest),
+		},
+		{
+			endpoint:      "/v1/wallets/new",
+			name:          "Valid mnemonic (json)",
+			method:        http.MethodPost,
+			body:          `{"label":"wallet2","aCount":1,"encrypted":false,"seed":"` + testSeed + `"}`,
+			headers:       CTApplicationJsonHeaders,
+			responseCode:  http.StatusOK,
 			checkResponse: alwaysValidChecker,
 		},
+		{
+			endpoint:      "/v1/wallets/new",
+			name:          "Mistyped mnemonic word (json)",
+			method:        http.MethodPost,
+			body:          `{"label":"wallet3","aCount":1,"encrypted":false,"seed":"` + strings.Replace(testSeed, "useful", "usefull", 1) + `"}`,
+			headers:       CTApplicationJsonHeaders,
+			responseCode:  http.StatusBadRequest,
+			checkResponse: seedErrorChecker(wallet.MnemonicUnknownWord, "usefull", 8),
+		},
+		{
+			endpoint:      "/v1/wallets/new",
+			name:          "Encrypted as string (json)",
+			method:        http.MethodPost,
+			body:          `{"label":"wallet3","aCount":1,"encrypted":"false","seed":"` + testSeed + `"}`,
+			headers:       CTApplicationJsonHeaders,
+			responseCode:  http.StatusBadRequest,
+			checkResponse: errorChecker(CodeInvalidRequest),
+		},
+		{
+			endpoint:      "/v1/wallets/get",
+			name:          "Get wallet (json)",
+			method:        http.MethodPost,
+			body:          `{"label":"wallet2","aCount":3}`,
+			headers:       CTApplicationJsonHeaders,
+			responseCode:  http.StatusOK,
+			checkResponse: entryCountChecker(3),
+		},
+		{
+			endpoint:      "/v1/wallets/get",
+			name:          "Get wallet",
+			method:        http.MethodPost,
+			body:          "label=wallet2&aCount=4",
+			headers:       CTApplicationFormHeaders,
+			responseCode:  http.StatusOK,
+			checkResponse: entryCountChecker(4),
+		},
+		/* error replies */
+		{
+			endpoint:      "/v1/wallets/new",
+			name:          "Negative aCount",
+			method:        http.MethodPost,
+			body:          "label=wallet3&aCount=-1&encrypted=false&seed=" + url.QueryEscape(testSeed),
+			headers:       CTApplicationFormHeaders,
+			responseCode:  http.StatusBadRequest,
+			checkResponse: errorChecker(CodeValueNotInRange),
+		},
+		{
+			endpoint:      "/v1/wallets/new",
+			name:          "Negative aCount (json)",
+			method:        http.MethodPost,
+			body:          `{"label":"wallet3","aCount":-1,"encrypted":false,"seed":"` + testSeed + `"}`,
+			headers:       CTApplicationJsonHeaders,
+			responseCode:  http.StatusBadRequest,
+			checkResponse: errorChecker(CodeValueNotInRange),
+		},
+		{
+			endpoint:      "/v1/wallets/new",
+			name:          "Invalid aCount",
+			method:        http.MethodPost,
+			body:          "label=wallet3&aCount=many&encrypted=false&seed=" + url.QueryEscape(testSeed),
+			headers:       CTApplicationFormHeaders,
+			responseCode:  http.StatusBadRequest,
+			checkResponse: errorChecker(CodeInvalidRequest),
+		},
+		{
+			endpoint:      "/v1/wallets/new",
+			name:          "Encrypted without password",
+			method:        http.MethodPost,
+			body:          "label=wallet3&aCount=1&encrypted=true&seed=" + url.QueryEscape(testSeed),
+			headers:       CTApplicationFormHeaders,
+			responseCode:  http.StatusBadRequest,
+			checkResponse: errorChecker(CodeInvalidPassword),
+		},
+		{
+			endpoint:      "/v1/wallets/get",
+			name:          "Negative aCount of get",
+			method:        http.MethodPost,
+			body:          "label=wallet2&aCount=-1",
+			headers:       CTApplicationFormHeaders,
+			responseCode:  http.StatusBadRequest,
+			checkResponse: errorChecker(CodeValueNotInRange),
+		},
+		{
+			endpoint:      "/v1/wallets/accounts/new",
+			name:          "Negative aCount of new account (json)",
+			method:        http.MethodPost,
+			body:          `{"label":"wallet2","name":"savings","aCount":-1}`,
+			headers:       CTApplicationJsonHeaders,
+			responseCode:  http.StatusBadRequest,
+			checkResponse: errorChecker(CodeValueNotInRange),
+		},
+		{
+			endpoint:      "/v1/wallets/get",
+			name:          "Wallet not found",
+			method:        http.MethodPost,
+			body:          "label=wallet9&aCount=1",
+			headers:       CTApplicationFormHeaders,
+			responseCode:  http.StatusNotFound,
+			checkResponse: errorChecker(CodeWalletNotFound),
+		},
+		{
+			endpoint:      "/v1/wallets/new",
+			name:          "Label already exists",
+			method:        http.MethodPost,
+			body:          "label=wallet2&aCount=1&encrypted=false&seed=" + url.QueryEscape(testSeed),
+			headers:       CTApplicationFormHeaders,
+			responseCode:  http.StatusConflict,
+			checkResponse: errorChecker(CodeLabelExists),
+		},
+		{
+			endpoint:      "/v1/wallets/get",
+			name:          "Invalid method",
+			method:        http.MethodGet,
+			headers:       CTApplicationFormHeaders,
+			responseCode:  http.StatusMethodNotAllowed,
+			checkResponse: errorChecker(ErrorCode("method_not_allowed")),
+		},
+		{
+			endpoint:      "/v1/wallets/get",
+			name:          "Unsupported content type",
+			method:        http.MethodPost,
+			body:          "wallet2",
+			headers:       map[string][]string{"Content-Type": {"text/plain"}},
+			responseCode:  http.StatusUnsupportedMediaType,
+			checkResponse: errorChecker(ErrorCode("unsupported_media_type")),
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -116,5 +329,43 @@ func validSeedChecker(t *testing.T, response *http.Response) {
 	require.NotEmpty(t, seedReply.Seed, "Should have a non-empty seed field")
 }
 
+func errorChecker(code ErrorCode) ResponseChecker {
+	return func(t *testing.T, response *http.Response) {
+		var reply ErrorReply
+		err := json.NewDecoder(response.Body).Decode(&reply)
+		require.NoError(t, err, "Should be able to decode an ErrorReply")
+		require.Equal(t, code, reply.Error.Code, "Should have the expected code")
+		require.NotEmpty(t, reply.Error.Message, "Should have an error message")
+	}
+}
+
+func seedErrorChecker(reason wallet.MnemonicErrorReason, word string, position int) ResponseChecker {
+	return func(t *testing.T, response *http.Response) {
+		var reply struct {
+			Error struct {
+				Code    ErrorCode   `json:"code"`
+				Message string      `json:"message"`
+				Details SeedDetails `json:"details"`
+			} `json:"error"`
+		}
+		err := json.NewDecoder(response.Body).Decode(&reply)
+		require.NoError(t, err, "Should be able to decode an ErrorReply")
+		require.Equal(t, CodeInvalidSeed, reply.Error.Code, "Should have the invalid seed code")
+		require.Equal(t, reason, reply.Error.Details.Reason, "Should have the expected reason")
+		require.Equal(t, word, reply.Error.Details.Word, "Should name the offending word")
+		require.Equal(t, position, reply.Error.Details.Position, "Should have the offending word's position")
+		require.NotEmpty(t, reply.Error.Message, "Should have an error message")
+	}
+}
+
 func alwaysValidChecker(t *testing.T, response *http.Response) {
 }
+
+func entryCountChecker(count int) ResponseChecker {
+	return func(t *testing.T, response *http.Response) {
+		var fw wallet.FloatingWallet
+		err := json.NewDecoder(response.Body).Decode(&fw)
+		require.NoError(t, err, "Should be able to decode a FloatingWallet")
+		require.Equal(t, count, fw.EntryCount, "Should have the expected entry count")
+	}
+}
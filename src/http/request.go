@@ -0,0 +1,174 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+/*
+	<<< REQUEST BODY >>>
+*/
+
+// Processor is implemented by requests that check their decoded values, and
+// set defaults of values that are not given.
+type Processor interface {
+	Process() error
+}
+
+// SwitchRequest decodes the body of r into req, a pointer to a request
+// struct, from either form or json encoding as of the 'Content-Type', and
+// processes it if it is a Processor. Both encodings use the json names of
+// the fields of req. The action is only called with a valid request,
+// otherwise the request is responded to with an ErrorReply.
+func SwitchRequest(w http.ResponseWriter, r *http.Request, req interface{}, action func() (bool, error)) (bool, error) {
+	decodeWith := func(decode func(*http.Request, interface{}) error) func() (bool, error) {
+		return func() (bool, error) {
+			if e := decode(r, req); e != nil {
+				return false, sendError(w, http.StatusBadRequest, e)
+			}
+			if p, ok := req.(Processor); ok {
+				if e := p.Process(); e != nil {
+					return false, sendError(w, http.StatusBadRequest, e)
+				}
+			}
+			return action()
+		}
+	}
+	return SwitchContType(w, r, ContTypeActions{
+		CtApplicationForm: decodeWith(decodeForm),
+		CtApplicationJson: decodeWith(decodeJson),
+	})
+}
+
+// decodeJson decodes a json request body into req. An empty body is an
+// empty request.
+func decodeJson(r *http.Request, req interface{}) error {
+	if r.Body == nil {
+		return nil
+	}
+	e := json.NewDecoder(r.Body).Decode(req)
+	switch e := e.(type) {
+	case nil:
+		return nil
+	case *json.UnmarshalTypeError:
+		if e.Field != "" {
+			return &fieldError{
+				field: e.Field,
+				msg:   fmt.Sprintf("invalid %s: expected %s, got %s", e.Field, e.Type, e.Value),
+			}
+		}
+	}
+	if e == io.EOF {
+		return nil
+	}
+	return &fieldError{msg: fmt.Sprintf("invalid request body: %v", e)}
+}
+
+// decodeForm decodes a form request body into req. Fields of embedded
+// structs are decoded as if they were fields of req.
+//
+// Values are parsed as of the field's type, and lists are comma separated.
+// Empty values are the same as values that are not given, except for
+// fields of type *string, which are set if the value is given at all.
+func decodeForm(r *http.Request, req interface{}) error {
+	if e := r.ParseForm(); e != nil {
+		return &fieldError{msg: fmt.Sprintf("invalid request body: %v", e)}
+	}
+	return decodeFormValues(r.PostForm, reflect.ValueOf(req).Elem())
+}
+
+func decodeFormValues(form url.Values, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if e := decodeFormValues(form, v.Field(i)); e != nil {
+				return e
+			}
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" || f.PkgPath != "" {
+			continue
+		}
+		values, ok := form[name]
+		if !ok {
+			continue
+		}
+		field, value := v.Field(i), values[0]
+		if value == "" && field.Type() != reflect.TypeOf((*string)(nil)) {
+			continue
+		}
+		if field.Kind() == reflect.Ptr {
+			field.Set(reflect.New(field.Type().Elem()))
+			field = field.Elem()
+		}
+		if e := setFormValue(field, value); e != nil {
+			return &fieldError{
+				field: name,
+				msg:   fmt.Sprintf("invalid %s: %v", name, e),
+			}
+		}
+	}
+	return nil
+}
+
+func setFormValue(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, e := strconv.ParseBool(value)
+		if e != nil {
+			return e
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, e := strconv.ParseInt(value, 10, v.Type().Bits())
+		if e != nil {
+			return e
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, e := strconv.ParseUint(value, 10, v.Type().Bits())
+		if e != nil {
+			return e
+		}
+		v.SetUint(n)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		items := reflect.MakeSlice(v.Type(), 0, 0)
+		for _, item := range splitList(value) {
+			items = reflect.Append(items, reflect.ValueOf(item).Convert(v.Type().Elem()))
+		}
+		v.Set(items)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// fieldError is returned for request bodies that can not be decoded, or of
+// which a value is missing or invalid. The field is empty if the body as a
+// whole is invalid.
+type fieldError struct {
+	field string
+	msg   string
+}
+
+func (e *fieldError) Error() string {
+	return e.msg
+}
+
+// errMissing is returned by requests of which a required value is not given.
+func errMissing(name string) error {
+	return &fieldError{field: name, msg: "missing " + name}
+}
@@ -0,0 +1,168 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testRequest struct {
+	WalletRequest
+	Count    int      `json:"count"`
+	Index    uint32   `json:"index"`
+	Enabled  *bool    `json:"enabled"`
+	Note     *string  `json:"note"`
+	Items    []string `json:"items"`
+	Required *int     `json:"required"`
+}
+
+func (req *testRequest) Process() error {
+	if req.Required == nil {
+		return errMissing("required")
+	}
+	return nil
+}
+
+func TestSwitchRequest(t *testing.T) {
+	var (
+		yes   = true
+		empty = ""
+		one   = 1
+	)
+	cases := []struct {
+		name   string
+		form   string
+		json   string
+		status int
+		exp    testRequest
+		field  string // Field of the invalid_request error, if not OK.
+	}{
+		{
+			name:   "all_values",
+			form:   "label=wallet0&password=pw&count=-2&index=3&enabled=true&note=hi&items=a,+b,&required=1",
+			json:   `{"label":"wallet0","password":"pw","count":-2,"index":3,"enabled":true,"note":"hi","items":["a","b"],"required":1}`,
+			status: http.StatusOK,
+			exp: testRequest{
+				WalletRequest: WalletRequest{Label: "wallet0", Password: "pw"},
+				Count:         -2,
+				Index:         3,
+				Enabled:       &yes,
+				Note:          newString("hi"),
+				Items:         []string{"a", "b"},
+				Required:      &one,
+			},
+		},
+		{
+			name:   "optional_values",
+			form:   "required=1",
+			json:   `{"required":1}`,
+			status: http.StatusOK,
+			exp:    testRequest{Required: &one},
+		},
+		{
+			name:   "empty_values",
+			form:   "label=&count=&enabled=&note=&required=1",
+			json:   `{"label":"","note":"","required":1}`,
+			status: http.StatusOK,
+			exp:    testRequest{Note: &empty, Required: &one},
+		},
+		{
+			name:   "missing_value",
+			form:   "label=wallet0",
+			json:   `{"label":"wallet0"}`,
+			status: http.StatusBadRequest,
+			field:  "required",
+		},
+		{
+			name:   "invalid_bool",
+			form:   "enabled=maybe&required=1",
+			json:   `{"enabled":"maybe","required":1}`,
+			status: http.StatusBadRequest,
+			field:  "enabled",
+		},
+		{
+			name:   "invalid_int",
+			form:   "count=many&required=1",
+			json:   `{"count":"many","required":1}`,
+			status: http.StatusBadRequest,
+			field:  "count",
+		},
+		{
+			name:   "out_of_range",
+			form:   "index=-1&required=1",
+			json:   `{"index":-1,"required":1}`,
+			status: http.StatusBadRequest,
+			field:  "index",
+		},
+	}
+	for _, c := range cases {
+		for contType, body := range map[ContTypeVal]string{
+			CtApplicationForm: c.form,
+			CtApplicationJson: c.json,
+		} {
+			t.Run(c.name+"/"+string(contType), func(t *testing.T) {
+				r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+				r.Header.Set(ContTypeKey, string(contType)+"; charset=utf-8")
+				w := httptest.NewRecorder()
+
+				var req testRequest
+				ok, err := SwitchRequest(w, r, &req, func() (bool, error) {
+					return true, sendJson(w, http.StatusOK, true)
+				})
+				require.NoError(t, err)
+				require.Equal(t, c.status == http.StatusOK, ok)
+				require.Equal(t, c.status, w.Code)
+				if ok {
+					require.Equal(t, c.exp, req)
+					return
+				}
+				var reply struct {
+					Error struct {
+						Code    ErrorCode    `json:"code"`
+						Details FieldDetails `json:"details"`
+					} `json:"error"`
+				}
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&reply))
+				require.Equal(t, CodeInvalidRequest, reply.Error.Code)
+				require.Equal(t, c.field, reply.Error.Details.Field)
+			})
+		}
+	}
+
+	// Empty json bodies are empty requests, and other content types are not
+	// accepted.
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set(ContTypeKey, string(CtApplicationJson))
+	var req WalletRequest
+	ok, err := SwitchRequest(httptest.NewRecorder(), r, &req, func() (bool, error) {
+		return true, nil
+	})
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("label"))
+	r.Header.Set(ContTypeKey, "text/plain")
+	w := httptest.NewRecorder()
+	ok, err = SwitchRequest(w, r, &req, func() (bool, error) {
+		return true, nil
+	})
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
+
+func TestBackupText_UnmarshalJSON(t *testing.T) {
+	var req ImportWalletRequest
+	require.NoError(t, json.Unmarshal([]byte(`{"backup":{"label":"wallet0"}}`), &req))
+	require.Equal(t, BackupText(`{"label":"wallet0"}`), req.Backup)
+	require.NoError(t, json.Unmarshal([]byte(`{"backup":"{\"label\":\"wallet0\"}"}`), &req))
+	require.Equal(t, BackupText(`{"label":"wallet0"}`), req.Backup)
+}
+
+func newString(v string) *string {
+	return &v
+}
@@ -155,9 +155,9 @@ func HostCheck(log *logrus.Logger, a *SplitAddressOut, mux *http.ServeMux) http.
 		if r.Host != "" && a.Localhost &&
 			r.Host != fmt.Sprintf("127.0.0.1:%d", a.Port) &&
 			r.Host != fmt.Sprintf("localhost:%d", a.Port) {
-			err := fmt.Sprintf("Detected DNS rebind attempt - configured-host=%s header-host=%s", r.Host, r.Host)
+			err := fmt.Errorf("Detected DNS rebind attempt - configured-host=%s header-host=%s", r.Host, r.Host)
 			log.Warn(err)
-			http.Error(w, err, http.StatusForbidden)
+			sendError(w, http.StatusForbidden, err)
 			return
 		}
 		mux.ServeHTTP(w, r)
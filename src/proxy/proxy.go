@@ -2,6 +2,8 @@ package proxy
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -56,6 +58,41 @@ func (p *Proxy) Redirect(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// BalanceReply is the kitty API's reply of '/v1/balance/{address}'.
+type BalanceReply struct {
+	Address string            `json:"address"`
+	Kitties []json.RawMessage `json:"kitties"`
+}
+
+// Balance queries the kitty API for the kitties owned by an address.
+func (p *Proxy) Balance(address string) (*BalanceReply, error) {
+	u := &url.URL{Path: "/v1/balance/" + url.PathEscape(address)}
+	resp, err := p.http.Get(p.c.TransformURL(u))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("kitty api replied to balance query with status %d",
+			resp.StatusCode)
+	}
+	out := new(BalanceReply)
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AddressUsed determines whether an address owns any kitties.
+// It implements 'wallet.AddressChecker'.
+func (p *Proxy) AddressUsed(address string) (bool, error) {
+	balance, err := p.Balance(address)
+	if err != nil {
+		return false, err
+	}
+	return len(balance.Kitties) > 0, nil
+}
+
 /*
 	<<< HELPER FUNCTIONS >>>
 */
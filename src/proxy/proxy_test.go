@@ -1,7 +1,11 @@
 package proxy
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -29,3 +33,33 @@ func TestConfig_TransformURL(t *testing.T) {
 		require.Equal(t, c.Exp, c.Config.TransformURL(u))
 	}
 }
+
+func TestProxy_AddressUsed(t *testing.T) {
+	// Local stand-in of the kitty API.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		address := strings.TrimPrefix(r.URL.Path, "/v1/balance/")
+		switch address {
+		case "used":
+			fmt.Fprintf(w, `{"address":%q,"kitties":[{"kitty_id":1}]}`, address)
+		case "unused":
+			fmt.Fprintf(w, `{"address":%q,"kitties":[]}`, address)
+		default:
+			http.Error(w, "invalid address", http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	p, err := New(&Config{Domain: srv.URL})
+	require.NoError(t, err)
+
+	used, err := p.AddressUsed("used")
+	require.NoError(t, err)
+	require.True(t, used)
+
+	used, err = p.AddressUsed("unused")
+	require.NoError(t, err)
+	require.False(t, used)
+
+	_, err = p.AddressUsed("invalid")
+	require.Error(t, err)
+}
@@ -54,6 +54,9 @@ func SignTransferParams(_ context.Context, in *SignTransferParamsIn) (*SignTrans
 	if err != nil {
 		return nil, errors.WithMessage(err, "provided secret key is invalid")
 	}
+	if err := secKey.Verify(); err != nil {
+		return nil, errors.WithMessage(err, "provided secret key is invalid")
+	}
 
 	// Sign.
 	var (
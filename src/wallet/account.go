@@ -0,0 +1,289 @@
+package wallet
+
+import (
+	"errors"
+	"unicode/utf8"
+
+	"github.com/skycoin/skycoin/src/cipher"
+
+	"github.com/watercompany/kittycash-wallet/src/wallet2"
+)
+
+var (
+	ErrAccountNotFound     = errors.New("account of wallet is not found")
+	ErrAccountNameExists   = errors.New("account name already exists in wallet")
+	ErrInvalidAccountName  = errors.New("invalid account name")
+	ErrAccountsUnsupported = errors.New("watch-only wallets can not have accounts")
+)
+
+const (
+	// DefaultAccountName is the name of the first account of a wallet.
+	DefaultAccountName = wallet2.DefaultAccountName
+
+	// MaxAccountNameLength is the maximum length of an account name, in
+	// characters.
+	MaxAccountNameLength = 64
+)
+
+// Account is a named account of a wallet. The entries of each account are
+// generated independently of other accounts: for HDDerivation, the account's
+// Index is the BIP44 account, and for SkycoinDerivation each account has it's
+// own key chain.
+//
+// The entries of the first account (of Index 0) are the Entries of the
+// wallet, as with wallets created before accounts were supported, so it's
+// Entries are always empty.
+//
+// Chain is where the account's key chain stopped, so that more entries are
+// generated from there. It is only used if it generated as many keys as the
+// account has entries.
+type Account struct {
+	Index   uint32
+	Name    string
+	Entries []Entry
+	Chain   Chain
+}
+
+// AccountStat represents an account when listed.
+type AccountStat struct {
+	Index      uint32 `json:"index"`
+	Name       string `json:"name"`
+	EntryCount int    `json:"entry_count"`
+}
+
+// FloatingAccount represents an account, and it's entries, that is
+// displayed in api.
+type FloatingAccount struct {
+	AccountStat
+	Entries []*FloatingEntry `json:"entries"`
+}
+
+// SecretFloatingAccount represents an account, including the secret keys of
+// it's entries, that is displayed in api when secrets are exported.
+type SecretFloatingAccount struct {
+	AccountStat
+	Entries []*SecretFloatingEntry `json:"entries"`
+}
+
+// defaultAccounts are the accounts of a new wallet.
+func defaultAccounts() []Account {
+	return []Account{{Index: 0, Name: DefaultAccountName}}
+}
+
+// VerifyAccountName checks that an account name is valid.
+func VerifyAccountName(name string) error {
+	if name == "" || !utf8.ValidString(name) ||
+		utf8.RuneCountInString(name) > MaxAccountNameLength {
+		return ErrInvalidAccountName
+	}
+	return nil
+}
+
+// NewAccount adds an account of name to the wallet, and generates n entries
+// for it. The new account is returned.
+func (w *Wallet) NewAccount(name string, n int) (*AccountStat, error) {
+	if w.IsWatchOnly() {
+		return nil, ErrAccountsUnsupported
+	}
+	if err := w.checkAccountName(name); err != nil {
+		return nil, err
+	}
+	var index uint32
+	for _, a := range w.Accounts {
+		if a.Index >= index {
+			index = a.Index + 1
+		}
+	}
+	if index >= HardenedKeyStart {
+		return nil, errors.New("wallet has too many accounts")
+	}
+
+	prevAccounts := w.Accounts
+	w.Accounts = append(w.Accounts, Account{Index: index, Name: name})
+	if err := w.EnsureAccountEntries(index, n); err != nil {
+		w.Accounts = prevAccounts
+		return nil, err
+	}
+	w.Meta.Saved = false
+	return w.accountStat(&w.Accounts[len(w.Accounts)-1]), nil
+}
+
+// RenameAccount renames the account of index.
+func (w *Wallet) RenameAccount(index uint32, name string) error {
+	a, err := w.account(index)
+	if err != nil {
+		return err
+	}
+	if a.Name == name {
+		return nil
+	}
+	if err := w.checkAccountName(name); err != nil {
+		return err
+	}
+	a.Name = name
+	w.Meta.Saved = false
+	return nil
+}
+
+// ListAccounts lists the accounts of the wallet.
+func (w *Wallet) ListAccounts() []AccountStat {
+	out := make([]AccountStat, len(w.Accounts))
+	for i := range w.Accounts {
+		out[i] = *w.accountStat(&w.Accounts[i])
+	}
+	return out
+}
+
+// EnsureAccountEntries ensures that the account of index has at least n
+// entries.
+func (w *Wallet) EnsureAccountEntries(index uint32, n int) error {
+	a, err := w.account(index)
+	if err != nil {
+		return err
+	}
+	entries := w.accountEntries(a)
+	switch {
+	case n < 0:
+		return ErrValueNotInRange{
+			ValName: "addresses",
+			HasMin:  true,
+			Got:     n,
+		}
+	case n <= len(entries):
+		return nil
+	case w.IsWatchOnly():
+		return ErrWatchOnly
+	}
+	// Only the missing entries are generated if the chain stopped at the
+	// last entry. Otherwise, the chain starts over.
+	chain := a.Chain
+	if chain.Count != uint64(len(entries)) {
+		chain = Chain{}
+	} else if len(entries) > 0 {
+		if err := w.verifyFirstEntry(index, entries[0]); err != nil {
+			return err
+		}
+	}
+	generate, err := w.newKeyGenerator(index, &chain)
+	if err != nil {
+		return err
+	}
+	from := int(chain.Count)
+	sks, err := generate(n - from)
+	if err != nil {
+		return err
+	}
+	if from == 0 && len(entries) > 0 && cipher.AddressFromSecKey(sks[0]) != entries[0].Address {
+		return ErrInvalidPassphrase
+	}
+	// Existing entries are kept, as they may have metadata.
+	out := make([]Entry, n)
+	copy(out, entries)
+	for i := len(entries); i < n; i++ {
+		entry, _ := NewEntry(sks[i-from])
+		out[i] = *entry
+	}
+	w.setAccountEntries(a, out)
+	a.Chain = chain
+
+	w.Meta.Saved = false
+	return nil
+}
+
+// account obtains the account of index.
+func (w *Wallet) account(index uint32) (*Account, error) {
+	for i := range w.Accounts {
+		if w.Accounts[i].Index == index {
+			return &w.Accounts[i], nil
+		}
+	}
+	return nil, ErrAccountNotFound
+}
+
+// accountCount obtains the number of entries of the account of index, which
+// is zero if there is no such account.
+func (w *Wallet) accountCount(index uint32) int {
+	a, err := w.account(index)
+	if err != nil {
+		return 0
+	}
+	return len(w.accountEntries(a))
+}
+
+func (w *Wallet) accountEntries(a *Account) []Entry {
+	if a.Index == 0 {
+		return w.Entries
+	}
+	return a.Entries
+}
+
+func (w *Wallet) setAccountEntries(a *Account, entries []Entry) {
+	if a.Index == 0 {
+		w.Entries = entries
+	} else {
+		a.Entries = entries
+	}
+}
+
+func (w *Wallet) accountStat(a *Account) *AccountStat {
+	return &AccountStat{
+		Index:      a.Index,
+		Name:       a.Name,
+		EntryCount: len(w.accountEntries(a)),
+	}
+}
+
+func (w *Wallet) checkAccountName(name string) error {
+	if err := VerifyAccountName(name); err != nil {
+		return err
+	}
+	for _, a := range w.Accounts {
+		if a.Name == name {
+			return ErrAccountNameExists
+		}
+	}
+	return nil
+}
+
+func accountsToFile(accounts []Account) []wallet2.Account {
+	if accounts == nil {
+		return nil
+	}
+	out := make([]wallet2.Account, len(accounts))
+	for i, a := range accounts {
+		out[i] = wallet2.Account{
+			Index:   a.Index,
+			Name:    a.Name,
+			Entries: entriesToFile(a.Entries),
+			Chain:   a.Chain,
+		}
+	}
+	return out
+}
+
+func accountsFromFile(accounts []wallet2.Account) []Account {
+	if accounts == nil {
+		return nil
+	}
+	out := make([]Account, len(accounts))
+	for i, a := range accounts {
+		out[i] = Account{
+			Index:   a.Index,
+			Name:    a.Name,
+			Entries: entriesFromFile(a.Entries),
+			Chain:   a.Chain,
+		}
+	}
+	return out
+}
+
+// skycoinAccountSeed obtains the seed of the key chain of an account of a
+// wallet with SkycoinDerivation. The first account uses the wallet's seed.
+func skycoinAccountSeed(seed []byte, index uint32) []byte {
+	if index == 0 {
+		return seed
+	}
+	data := append([]byte("kittycash account"), uint32Bytes(index)...)
+	sum := cipher.SumSHA256(append(data, seed...))
+	return sum[:]
+}
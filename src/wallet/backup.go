@@ -0,0 +1,138 @@
+package wallet
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+var (
+	ErrInvalidBackup            = errors.New("invalid wallet backup")
+	ErrUnsupportedBackupVersion = errors.New("wallet backup version is not supported")
+)
+
+const (
+	// BackupFormat identifies a wallet backup.
+	BackupFormat = "kittycash-wallet-backup"
+
+	// BackupVersion determines the wallet backup's version.
+	BackupVersion uint64 = 1
+)
+
+// Backup is a portable wallet backup. It holds the wallet file, along with
+// enough information to describe the file without decoding it.
+type Backup struct {
+	Format      string     `json:"format"`
+	Version     uint64     `json:"version"`
+	Label       string     `json:"label"`
+	FileVersion uint64     `json:"file_version"`
+	Encrypted   bool       `json:"encrypted"`
+	Prefix      string     `json:"prefix"`
+	KDF         *BackupKDF `json:"kdf,omitempty"`
+	Data        string     `json:"data"`
+	Checksum    string     `json:"checksum"`
+}
+
+// BackupKDF describes the key derivation of an encrypted wallet file.
+type BackupKDF struct {
+	Algorithm string `json:"algorithm"`
+	Salt      string `json:"salt"`
+	N         uint64 `json:"n"`
+	R         uint64 `json:"r"`
+	P         uint64 `json:"p"`
+}
+
+// NewBackup creates a backup of the raw wallet file of label.
+func NewBackup(label string, raw []byte) (*Backup, error) {
+	prefix, data, err := ExtractPrefix(raw)
+	if err != nil {
+		return nil, err
+	}
+	b := &Backup{
+		Format:      BackupFormat,
+		Version:     BackupVersion,
+		Label:       label,
+		FileVersion: prefix.Version(),
+		Encrypted:   prefix.Encrypted(),
+		Prefix:      hex.EncodeToString(prefix[:]),
+		Data:        base64.StdEncoding.EncodeToString(raw),
+		Checksum:    cipher.SumSHA256(raw).Hex(),
+	}
+	if b.Encrypted && b.FileVersion > 0 {
+		params, _, err := ExtractKDFParams(data)
+		if err != nil {
+			return nil, err
+		}
+		b.KDF = &BackupKDF{
+			Algorithm: "scrypt",
+			Salt:      hex.EncodeToString(params.Salt[:]),
+			N:         params.N,
+			R:         params.R,
+			P:         params.P,
+		}
+	}
+	return b, nil
+}
+
+// ReadBackup decodes a backup, and verifies it's contents.
+func ReadBackup(r io.Reader) (*Backup, []byte, error) {
+	b := new(Backup)
+	if err := json.NewDecoder(r).Decode(b); err != nil {
+		return nil, nil, fmt.Errorf("%v: %v", ErrInvalidBackup, err)
+	}
+	raw, err := b.Verify()
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, raw, nil
+}
+
+// Verify checks that the backup is supported and consistent, and returns the
+// raw wallet file.
+func (b *Backup) Verify() ([]byte, error) {
+	if b.Format != BackupFormat {
+		return nil, ErrInvalidBackup
+	}
+	if b.Version > BackupVersion {
+		return nil, ErrUnsupportedBackupVersion
+	}
+	if b.FileVersion > Version {
+		return nil, ErrUnsupportedVersion
+	}
+	raw, err := base64.StdEncoding.DecodeString(b.Data)
+	if err != nil {
+		return nil, ErrInvalidBackup
+	}
+	if cipher.SumSHA256(raw).Hex() != b.Checksum {
+		return nil, ErrCorruptFile
+	}
+	prefix, _, err := ExtractPrefix(raw)
+	if err != nil {
+		return nil, err
+	}
+	if hex.EncodeToString(prefix[:]) != b.Prefix ||
+		prefix.Version() != b.FileVersion ||
+		prefix.Encrypted() != b.Encrypted {
+		return nil, ErrInvalidBackup
+	}
+	return raw, nil
+}
+
+// ImportOptions for importing a wallet backup.
+type ImportOptions struct {
+	// Label of the imported wallet. The backup's label is used if empty.
+	Label string `json:"label"`
+
+	// Rename picks a free label if the label is already taken, instead of
+	// failing with ErrLabelAlreadyExists.
+	Rename bool `json:"rename"`
+
+	// Password of an encrypted backup. If given, the backup is decrypted
+	// before it is imported and the wallet is left unlocked.
+	Password string `json:"password,omitempty"`
+}
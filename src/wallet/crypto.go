@@ -0,0 +1,175 @@
+package wallet
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"golang.org/x/crypto/chacha20poly1305"
+
+	"github.com/watercompany/kittycash-wallet/src/wallet2"
+)
+
+var (
+	ErrCorruptFile = errors.New("wallet file is corrupt or has been tampered with")
+)
+
+const (
+	KeyCheckSize = sha256.Size
+	ChecksumSize = sha256.Size
+
+	keyCheckMessage = "kittycash wallet key check"
+)
+
+// encodeFile encodes a wallet file of the latest Version.
+//
+// An encrypted file is laid out as:
+//
+//	Prefix | KDFParams | key check | File sealed with ChaCha20-Poly1305
+//
+// The key check is a HMAC of the derived key, and lets us tell a wrong
+// password apart from a tampered file. The header (everything before the
+// sealed File) is authenticated as additional data.
+//
+// An unencrypted file is laid out as:
+//
+//	Prefix | File | SHA256 of everything before it
+func encodeFile(f *File, encrypted bool, password string) ([]byte, error) {
+	data := f.Serialize()
+
+	if !encrypted {
+		prefix := NewPrefix(Version, EmptyNonce())
+		raw := append(prefix[:], data...)
+		sum := cipher.SumSHA256(raw)
+		return append(raw, sum[:]...), nil
+	}
+
+	var (
+		nonce  = RandNonce()
+		prefix = NewPrefix(Version, nonce)
+		params = NewKDFParams()
+	)
+	key, err := params.Key(password)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	header := append(prefix[:], params.Serialize()...)
+	header = append(header, keyCheck(key)...)
+	return aead.Seal(header, aeadNonce(nonce), data, header), nil
+}
+
+// decodeFile verifies, decrypts (if needed) and decodes the data that
+// follows the Prefix of a wallet file.
+func decodeFile(prefix Prefix, data []byte, password string) (*File, error) {
+	switch v := prefix.Version(); {
+	case v > Version:
+		return nil, ErrUnsupportedVersion
+	case v < 2:
+		return decodeLegacyFile(prefix, data, password)
+	}
+
+	if !prefix.Encrypted() {
+		if len(data) < ChecksumSize {
+			return nil, ErrCorruptFile
+		}
+		var (
+			body = data[:len(data)-ChecksumSize]
+			sum  = cipher.SumSHA256(append(prefix[:], body...))
+		)
+		if !hmac.Equal(sum[:], data[len(body):]) {
+			return nil, ErrCorruptFile
+		}
+		return decodeVerifiedFile(prefix.Version(), body)
+	}
+
+	params, sealed, err := ExtractKDFParams(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < KeyCheckSize {
+		return nil, ErrCorruptFile
+	}
+	check, sealed := sealed[:KeyCheckSize], sealed[KeyCheckSize:]
+
+	key, err := params.Key(password)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(check, keyCheck(key)) {
+		return nil, ErrInvalidPassword
+	}
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	header := append(prefix[:], data[:KDFParamsSize+KeyCheckSize]...)
+	body, err := aead.Open(nil, aeadNonce(prefix.Nonce()), sealed, header)
+	if err != nil {
+		return nil, ErrCorruptFile
+	}
+	return decodeVerifiedFile(prefix.Version(), body)
+}
+
+// decodeVerifiedFile decodes a File which passed integrity checks, so any
+// failure means that the file was written incorrectly.
+func decodeVerifiedFile(version uint64, data []byte) (*File, error) {
+	f, err := wallet2.Decode(version, data)
+	if err != nil {
+		log.Errorf("failed to decode verified wallet file, error: %v", err)
+		return nil, ErrCorruptFile
+	}
+	return f, nil
+}
+
+// decodeLegacyFile decodes version 0 and 1 wallet files. These have no
+// integrity checks, so a wrong password is only detected when decoding fails.
+func decodeLegacyFile(prefix Prefix, data []byte, password string) (*File, error) {
+	if prefix.Encrypted() {
+		var key []byte
+		if prefix.Version() == 0 {
+			key = legacyKey(password)
+		} else {
+			params, rest, err := ExtractKDFParams(data)
+			if err != nil {
+				return nil, err
+			}
+			if key, err = params.Key(password); err != nil {
+				return nil, err
+			}
+			data = rest
+		}
+		var err error
+		if data, err = cipher.Chacha20Decrypt(data, key, prefix.Nonce()); err != nil {
+			log.Errorf("failed to decrypt wallet file, error: %v", err)
+			return nil, ErrInvalidCredentials
+		}
+	}
+	f, err := wallet2.Decode(prefix.Version(), data)
+	if err != nil {
+		log.Errorf("failed to decode wallet file, error: %v", err)
+		return nil, ErrInvalidCredentials
+	}
+	return f, nil
+}
+
+// keyCheck generates the value stored in encrypted files to verify the key
+// derived from the password.
+func keyCheck(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(keyCheckMessage))
+	return mac.Sum(nil)
+}
+
+// aeadNonce extends the Prefix nonce to the nonce size of ChaCha20-Poly1305.
+// As the key is derived with a new salt on every save, the nonce is never
+// reused with the same key.
+func aeadNonce(nonce []byte) []byte {
+	out := make([]byte, chacha20poly1305.NonceSize)
+	copy(out, nonce)
+	return out
+}
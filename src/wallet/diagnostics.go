@@ -0,0 +1,62 @@
+package wallet
+
+import (
+	"encoding/hex"
+)
+
+// Diagnostic represents a wallet file when inspected by 'Diagnose'. Prefix is
+// the hex encoded prefix of the file, which is empty if the file is too
+// small to have one.
+type Diagnostic struct {
+	Label     string     `json:"label"`
+	Status    FileStatus `json:"status"`
+	Reason    string     `json:"reason,omitempty"`
+	Size      int64      `json:"size"`
+	Prefix    string     `json:"prefix,omitempty"`
+	Version   uint64     `json:"version"`
+	Encrypted bool       `json:"encrypted"`
+
+	// LabelError is why the label does not follow the label policy.
+	LabelError string `json:"label_error,omitempty"`
+}
+
+// Diagnose inspects the file of every listed wallet, to find out why wallet
+// files are quarantined.
+func (m *Manager) Diagnose() []Diagnostic {
+	m.mux.Lock()
+	out := make([]Diagnostic, len(m.labels))
+	for i, label := range m.labels {
+		out[i] = Diagnostic{
+			Label:      label,
+			Status:     FileOK,
+			LabelError: m.badLabels[label],
+		}
+		if _, ok := m.unsupported[label]; ok {
+			out[i].Status = FileUnsupported
+			out[i].Reason = ErrUnsupportedVersion.Error()
+		}
+		if reason, ok := m.quarantined[label]; ok {
+			out[i].Status = FileQuarantined
+			out[i].Reason = reason
+		}
+	}
+	m.mux.Unlock()
+
+	for i := range out {
+		d := &out[i]
+		raw, err := m.c.Storage.Read(d.Label)
+		if err != nil {
+			if d.Reason == "" {
+				d.Reason = err.Error()
+			}
+			continue
+		}
+		d.Size = int64(len(raw))
+		if prefix, _, err := ExtractPrefix(raw); err == nil {
+			d.Prefix = hex.EncodeToString(prefix[:])
+			d.Version = prefix.Version()
+			d.Encrypted = prefix.Encrypted()
+		}
+	}
+	return out
+}
@@ -2,22 +2,93 @@ package wallet
 
 import (
 	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
 
 	"github.com/skycoin/skycoin/src/cipher"
+
+	"github.com/watercompany/kittycash-wallet/src/wallet2"
+)
+
+var (
+	ErrEntryNotFound = errors.New("address of wallet is not found")
+)
+
+const (
+	// MaxEntryLabelLength is the maximum length of an entry's label, in
+	// characters.
+	MaxEntryLabelLength = 64
+
+	// MaxEntryNoteLength is the maximum length of an entry's note, in
+	// characters.
+	MaxEntryNoteLength = 1024
 )
 
-// FloatingEntry represents a readable wallet entry.
+// FloatingEntry represents a readable wallet entry, without it's secret key.
 type FloatingEntry struct {
-	Address string `json:"address"`
-	PubKey  string `json:"public_key"`
-	SecKey  string `json:"secret_key"`
+	Address  string `json:"address"`
+	PubKey   string `json:"public_key"`
+	Imported bool   `json:"imported"`
+	Label    string `json:"label,omitempty"`
+	Note     string `json:"note,omitempty"`
+	TS       int64  `json:"timestamp,omitempty"`
+	Hidden   bool   `json:"hidden"`
+}
+
+// SecretFloatingEntry represents a readable wallet entry, including it's
+// secret key.
+type SecretFloatingEntry struct {
+	FloatingEntry
+	SecKey string `json:"secret_key"`
+}
+
+// Entry represents a wallet entry. It's fields are those stored in file.
+type Entry wallet2.Entry
+
+// EntryUpdate is a change to the user set metadata of an entry. Fields that
+// are nil are left unchanged.
+type EntryUpdate struct {
+	Label  *string
+	Note   *string
+	Hidden *bool
 }
 
-// Entry represents a wallet entry.
-type Entry struct {
-	Address cipher.Address
-	PubKey  cipher.PubKey
-	SecKey  cipher.SecKey
+// Verify checks that the update's label and note are valid.
+func (u *EntryUpdate) Verify() error {
+	check := func(name string, v *string, max int) error {
+		switch {
+		case v == nil:
+			return nil
+		case !utf8.ValidString(*v):
+			return fmt.Errorf("%s is not valid UTF-8", name)
+		case utf8.RuneCountInString(*v) > max:
+			return ErrValueNotInRange{
+				ValName: name,
+				HasMax:  true,
+				ExpMax:  max,
+				Got:     utf8.RuneCountInString(*v),
+			}
+		}
+		return nil
+	}
+	if err := check("entry_label", u.Label, MaxEntryLabelLength); err != nil {
+		return err
+	}
+	return check("entry_note", u.Note, MaxEntryNoteLength)
+}
+
+// Apply applies the update to an entry.
+func (u *EntryUpdate) Apply(we *Entry) {
+	if u.Label != nil {
+		we.Label = *u.Label
+	}
+	if u.Note != nil {
+		we.Note = *u.Note
+	}
+	if u.Hidden != nil {
+		we.Hidden = *u.Hidden
+	}
 }
 
 // NewEntry creates a new wallet entry.
@@ -29,15 +100,69 @@ func NewEntry(sk cipher.SecKey) (*Entry, error) {
 		Address: cipher.AddressFromSecKey(sk),
 		PubKey:  cipher.PubKeyFromSecKey(sk),
 		SecKey:  sk,
+		TS:      time.Now().UnixNano(),
 	}, nil
 }
 
+// NewWatchEntry creates a watch-only wallet entry from a string that is
+// either an address, or a hex encoded public key.
+func NewWatchEntry(v string) (*Entry, error) {
+	if addr, err := cipher.DecodeBase58Address(v); err == nil {
+		return &Entry{Address: addr, TS: time.Now().UnixNano()}, nil
+	}
+	pk, err := cipher.PubKeyFromHex(v)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' is neither an address nor a public key", v)
+	}
+	if err := pk.Verify(); err != nil {
+		return nil, fmt.Errorf("'%s' is an invalid public key: %v", v, err)
+	}
+	return &Entry{
+		Address: cipher.AddressFromPubKey(pk),
+		PubKey:  pk,
+		TS:      time.Now().UnixNano(),
+	}, nil
+}
+
+// NewWatchEntries creates watch-only wallet entries, see NewWatchEntry.
+func NewWatchEntries(vs []string) ([]Entry, error) {
+	out := make([]Entry, len(vs))
+	for i, v := range vs {
+		entry, err := NewWatchEntry(v)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = *entry
+	}
+	return out, nil
+}
+
 // ToFloating converts a wallet entry to a readable format.
 func (we *Entry) ToFloating() *FloatingEntry {
-	return &FloatingEntry{
+	out := &FloatingEntry{
 		Address: we.Address.String(),
-		PubKey:  we.PubKey.Hex(),
-		SecKey:  we.SecKey.Hex(),
+		Label:   we.Label,
+		Note:    we.Note,
+		TS:      we.TS,
+		Hidden:  we.Hidden,
+	}
+	if we.HasPubKey() {
+		out.PubKey = we.PubKey.Hex()
+	}
+	return out
+}
+
+// HasPubKey returns false for watch-only entries created from an address.
+func (we *Entry) HasPubKey() bool {
+	return we.PubKey != cipher.PubKey{}
+}
+
+// ToSecretFloating converts a wallet entry to a readable format, including
+// it's secret key.
+func (we *Entry) ToSecretFloating() *SecretFloatingEntry {
+	return &SecretFloatingEntry{
+		FloatingEntry: *we.ToFloating(),
+		SecKey:        we.SecKey.Hex(),
 	}
 }
 
@@ -57,3 +182,25 @@ func (we *Entry) VerifyPublic() error {
 	}
 	return we.Address.Verify(we.PubKey)
 }
+
+func entriesToFile(entries []Entry) []wallet2.Entry {
+	if entries == nil {
+		return nil
+	}
+	out := make([]wallet2.Entry, len(entries))
+	for i, e := range entries {
+		out[i] = wallet2.Entry(e)
+	}
+	return out
+}
+
+func entriesFromFile(entries []wallet2.Entry) []Entry {
+	if entries == nil {
+		return nil
+	}
+	out := make([]Entry, len(entries))
+	for i, e := range entries {
+		out[i] = Entry(e)
+	}
+	return out
+}
@@ -0,0 +1,127 @@
+package wallet
+
+import (
+	"time"
+)
+
+const (
+	// eventBuffer is the number of events that are queued for a subscriber
+	// before further events are dropped.
+	eventBuffer = 64
+)
+
+// EventType determines what happened to a wallet.
+type EventType string
+
+const (
+	// EventCreated represents a wallet that was created, restored, imported,
+	// or of which the file appeared.
+	EventCreated EventType = "created"
+
+	// EventDeleted represents a wallet that was deleted, or of which the file
+	// disappeared.
+	EventDeleted EventType = "deleted"
+
+	// EventRenamed represents a wallet that was renamed from the label From.
+	EventRenamed EventType = "renamed"
+
+	// EventReloaded represents a wallet of which the file was replaced, by
+	// restoring a backup or outside of the manager. The wallet is reloaded,
+	// and so is locked if encrypted.
+	EventReloaded EventType = "reloaded"
+
+	// EventUnlocked represents an encrypted wallet that was decrypted.
+	EventUnlocked EventType = "unlocked"
+
+	// EventLocked represents an encrypted wallet that was locked.
+	EventLocked EventType = "locked"
+
+	// EventEntriesAdded represents entries that were added to an account of a
+	// wallet. Imported and watch-only entries are of the first account.
+	EventEntriesAdded EventType = "entries_added"
+)
+
+// Event represents something that happened to a wallet. Seq increases with
+// each event, so that subscribers can tell if they missed events.
+type Event struct {
+	Seq   uint64    `json:"seq"`
+	Type  EventType `json:"type"`
+	Label string    `json:"label"`
+	TS    int64     `json:"timestamp"`
+
+	// From is the previous label of a renamed wallet.
+	From string `json:"from,omitempty"`
+
+	// Account and Added are the account that entries were added to, and the
+	// number of entries added.
+	Account uint32 `json:"account,omitempty"`
+	Added   int    `json:"added,omitempty"`
+}
+
+// Subscribe subscribes to the events of the manager, which are sent on the
+// returned channel until the returned function is called, or the manager is
+// closed. Events are dropped (rather than blocking the manager) for
+// subscribers that do not keep up, which is seen as a gap in Seq.
+func (m *Manager) Subscribe() (<-chan Event, func()) {
+	m.subMux.Lock()
+	defer m.subMux.Unlock()
+
+	c := make(chan Event, eventBuffer)
+	if m.subs == nil {
+		// The manager is closed.
+		close(c)
+		return c, func() {}
+	}
+	m.subs[c] = struct{}{}
+	return c, func() {
+		m.subMux.Lock()
+		defer m.subMux.Unlock()
+		if _, ok := m.subs[c]; ok {
+			delete(m.subs, c)
+			close(c)
+		}
+	}
+}
+
+// publish sends an event to every subscriber. It may be called with any
+// other lock held, as it never blocks.
+func (m *Manager) publish(e Event) {
+	m.subMux.Lock()
+	defer m.subMux.Unlock()
+
+	m.eventSeq++
+	e.Seq = m.eventSeq
+	e.TS = time.Now().UnixNano()
+	for c := range m.subs {
+		select {
+		case c <- e:
+		default:
+			log.Warningf("event %d of wallet `%s` is dropped for a slow subscriber",
+				e.Seq, e.Label)
+		}
+	}
+}
+
+// closeSubs ends all subscriptions.
+func (m *Manager) closeSubs() {
+	m.subMux.Lock()
+	defer m.subMux.Unlock()
+
+	for c := range m.subs {
+		close(c)
+	}
+	m.subs = nil
+}
+
+// entriesAdded publishes EventEntriesAdded if an account of a wallet has
+// more entries than prev.
+func (m *Manager) entriesAdded(w *Wallet, account uint32, prev int) {
+	if n := w.accountCount(account); n > prev {
+		m.publish(Event{
+			Type:    EventEntriesAdded,
+			Label:   w.Meta.Label,
+			Account: account,
+			Added:   n - prev,
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_Events(t *testing.T) {
+	m, done := newTestManager(t)
+	defer done()
+
+	events, cancel := m.Subscribe()
+	defer cancel()
+
+	// next receives the events published so far, without their Seq and TS.
+	var seq uint64
+	next := func() []Event {
+		var out []Event
+		for {
+			select {
+			case e := <-events:
+				require.Equal(t, seq+1, e.Seq)
+				seq = e.Seq
+				e.Seq, e.TS = 0, 0
+				out = append(out, e)
+			default:
+				return out
+			}
+		}
+	}
+
+	require.NoError(t, m.NewWallet(&Options{
+		Label:     "wallet0",
+		Seed:      testSeed,
+		Encrypted: true,
+		Password:  "password",
+	}, 2))
+	require.Equal(t, []Event{{Type: EventCreated, Label: "wallet0"}}, next())
+
+	_, err := m.DisplayWallet("wallet0", "", 5)
+	require.NoError(t, err)
+	_, err = m.NewAccount("wallet0", "", "savings", 3)
+	require.NoError(t, err)
+	_, err = m.DisplayPaginatedWallet("wallet0", "", 1, 0, 1, 4)
+	require.NoError(t, err)
+	require.Equal(t, []Event{
+		{Type: EventEntriesAdded, Label: "wallet0", Added: 3},
+		{Type: EventEntriesAdded, Label: "wallet0", Account: 1, Added: 3},
+		{Type: EventEntriesAdded, Label: "wallet0", Account: 1, Added: 1},
+	}, next())
+
+	// Entries that already exist are not added.
+	_, err = m.DisplayWallet("wallet0", "", 5)
+	require.NoError(t, err)
+	require.Empty(t, next())
+
+	require.NoError(t, m.Lock("wallet0"))
+	require.NoError(t, m.Unlock("wallet0", "password", ""))
+	require.NoError(t, m.RenameWallet("wallet0", "wallet1"))
+	require.NoError(t, m.DeleteWallet("wallet1"))
+	require.Equal(t, []Event{
+		{Type: EventLocked, Label: "wallet0"},
+		{Type: EventUnlocked, Label: "wallet0"},
+		{Type: EventRenamed, Label: "wallet1", From: "wallet0"},
+		{Type: EventDeleted, Label: "wallet1"},
+	}, next())
+
+	// Changes of wallet files outside of the manager are published too.
+	require.NoError(t, saveWallet(&Options{
+		Label: "wallet2",
+		Seed:  testSeed,
+	}))
+	require.NoError(t, m.poll())
+	require.Equal(t, []Event{{Type: EventCreated, Label: "wallet2"}}, next())
+
+	// Cancelled subscriptions receive no more events.
+	cancel()
+	require.NoError(t, m.DeleteWallet("wallet2"))
+	_, ok := <-events
+	require.False(t, ok)
+	cancel()
+
+	// Subscriptions end when the manager is closed.
+	closed, err := NewManager(&ManagerConfig{Storage: NewMemoryStorage()})
+	require.NoError(t, err)
+	events, _ = closed.Subscribe()
+	closed.Close()
+	closed.Close()
+	_, ok = <-events
+	require.False(t, ok)
+	events, _ = closed.Subscribe()
+	_, ok = <-events
+	require.False(t, ok)
+}
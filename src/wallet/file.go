@@ -17,9 +17,10 @@ var (
 	log = logrus.New()
 )
 
-// LabelPath obtains the path to the wallet file of the given label.
+// LabelPath obtains the path to the wallet file of the given label. Labels
+// that are not plain are encoded (see VerifyLabel).
 func LabelPath(rootDir, label string) string {
-	return filepath.Join(rootDir, fmt.Sprintf("%s%s", label, FileExt))
+	return filepath.Join(rootDir, fmt.Sprintf("%s%s", labelFilename(label), FileExt))
 }
 
 type LabelAction func(data []byte, label, fPath string, prefix Prefix) error
@@ -37,8 +38,8 @@ func RangeLabels(rootDir string, action LabelAction) error {
 		if strings.HasSuffix(name, string(FileExt)) == false {
 			continue
 		}
-		label := strings.TrimSuffix(name, string(FileExt))
-		fPath := LabelPath(rootDir, label)
+		label := filenameLabel(strings.TrimSuffix(name, string(FileExt)))
+		fPath := filepath.Join(rootDir, name)
 
 		data, err := OpenAndReadAll(fPath)
 		if err != nil {
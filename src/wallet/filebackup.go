@@ -0,0 +1,166 @@
+package wallet
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrFileBackupNotFound = errors.New("wallet file backup is not found")
+)
+
+const (
+	// BackupDir is the subdirectory of the wallets' root directory which
+	// holds the backups of wallet files.
+	BackupDir = ".backups"
+
+	// DefaultBackupCount is the default number of backups kept of each
+	// wallet file.
+	DefaultBackupCount = 5
+)
+
+// FileBackup represents a backup of a wallet file, which is a copy of the
+// file as it was before being overwritten or deleted.
+type FileBackup struct {
+	ID        string `json:"id"`
+	TS        int64  `json:"timestamp"`
+	Version   uint64 `json:"version"`
+	Encrypted bool   `json:"encrypted"`
+	Size      int64  `json:"size"`
+}
+
+// BackupDirPath obtains the path to the directory of the backups of the
+// wallet files in rootDir.
+func BackupDirPath(rootDir string) string {
+	return filepath.Join(rootDir, BackupDir)
+}
+
+// Backups are stored under the label '<label>.<id>', where the id is the
+// time of the backup. As ids have no dots, the label of a backup is
+// unambiguous.
+func backupLabel(label, id string) string {
+	return label + "." + id
+}
+
+// BackupFile copies the wallet file of label from files to backups, and
+// removes the oldest backups so that at most n are kept. Nothing is done if
+// n is not positive, or there is no wallet file.
+func BackupFile(files, backups Storage, label string, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	raw, err := files.Read(label)
+	switch {
+	case err == ErrFileNotFound:
+		return nil
+	case err != nil:
+		return err
+	}
+	id, err := newBackupID(backups, label, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := backups.Write(backupLabel(label, id), raw); err != nil {
+		return err
+	}
+
+	list, err := ListFileBackups(backups, label)
+	if err != nil {
+		return err
+	}
+	for len(list) > n {
+		old := list[len(list)-1]
+		if err := backups.Delete(backupLabel(label, old.ID)); err != nil {
+			return err
+		}
+		list = list[:len(list)-1]
+	}
+	return nil
+}
+
+// newBackupID obtains the id of a new backup of label, which is the time of
+// now, or a later one if a backup of that time already exists.
+func newBackupID(backups Storage, label string, now time.Time) (string, error) {
+	for ts := now.UnixNano(); ; ts++ {
+		id := strconv.FormatInt(ts, 10)
+		_, err := backups.Read(backupLabel(label, id))
+		switch {
+		case err == ErrFileNotFound:
+			return id, nil
+		case err != nil:
+			return "", err
+		}
+	}
+}
+
+// ListFileBackups lists the backups of the wallet file of label, newest
+// first.
+func ListFileBackups(backups Storage, label string) ([]FileBackup, error) {
+	labels, err := backups.List()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]FileBackup, 0)
+	for _, l := range labels {
+		i := strings.LastIndex(l, ".")
+		if i < 0 || l[:i] != label {
+			continue
+		}
+		id := l[i+1:]
+		ts, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			continue
+		}
+		raw, err := backups.Read(l)
+		if err != nil {
+			return nil, err
+		}
+		prefix, _, err := ExtractPrefix(raw)
+		if err != nil {
+			log.Warningf("backup `%s` of wallet `%s` is invalid: %v", id, label, err)
+			continue
+		}
+		out = append(out, FileBackup{
+			ID:        id,
+			TS:        ts,
+			Version:   prefix.Version(),
+			Encrypted: prefix.Encrypted(),
+			Size:      int64(len(raw)),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].TS > out[j].TS
+	})
+	return out, nil
+}
+
+// ReadFileBackup reads the backup of id of the wallet file of label.
+func ReadFileBackup(backups Storage, label, id string) ([]byte, error) {
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		return nil, ErrFileBackupNotFound
+	}
+	raw, err := backups.Read(backupLabel(label, id))
+	if err == ErrFileNotFound {
+		return nil, ErrFileBackupNotFound
+	}
+	return raw, err
+}
+
+// moveFileBackups moves the backups of the wallet file of label to be of
+// newLabel.
+func moveFileBackups(backups Storage, label, newLabel string) error {
+	list, err := ListFileBackups(backups, label)
+	if err != nil {
+		return err
+	}
+	for _, b := range list {
+		if err := backups.Rename(backupLabel(label, b.ID), backupLabel(newLabel, b.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package wallet
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBackupID(t *testing.T) {
+	backups := NewMemoryStorage()
+	now := time.Unix(0, 1000)
+
+	// Backups of the same time get later ids, rather than replacing each
+	// other.
+	for i := int64(0); i < 3; i++ {
+		id, err := newBackupID(backups, "wallet0", now)
+		require.NoError(t, err)
+		require.Equal(t, strconv.FormatInt(1000+i, 10), id)
+		require.NoError(t, backups.Write(backupLabel("wallet0", id), []byte{}))
+	}
+
+	// Ids are per label.
+	id, err := newBackupID(backups, "wallet1", now)
+	require.NoError(t, err)
+	require.Equal(t, "1000", id)
+}
@@ -0,0 +1,335 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/base58"
+	secp "github.com/skycoin/skycoin/src/cipher/secp256k1-go/secp256k1-go2"
+)
+
+var (
+	ErrInvalidExtendedKey = errors.New("invalid extended key")
+	ErrHardenedFromPublic = errors.New("can not derive a hardened child from a public extended key")
+	ErrInvalidChild       = errors.New("child key is invalid, the next index should be used")
+)
+
+const (
+	// HardenedKeyStart is the index of the first hardened child key.
+	HardenedKeyStart uint32 = 0x80000000
+
+	// HDPurpose is the BIP44 purpose.
+	HDPurpose = 44
+	// HDCoinType is the BIP44 coin type, which is registered to skycoin.
+	HDCoinType = 8000
+
+	// ExternalChain is the BIP44 chain of receiving addresses.
+	ExternalChain uint32 = 0
+	// ChangeChain is the BIP44 chain of change addresses.
+	ChangeChain uint32 = 1
+
+	hdMasterKey     = "Bitcoin seed"
+	extendedKeySize = 78
+)
+
+var (
+	xprvVersion = []byte{0x04, 0x88, 0xad, 0xe4}
+	xpubVersion = []byte{0x04, 0x88, 0xb2, 0x1e}
+
+	// curveOrder is the order of the secp256k1 curve.
+	curveOrder, _ = new(big.Int).SetString(
+		"fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
+)
+
+// ExtendedKey is a BIP32 extended key. It holds a secret key if it is
+// private, and only a public key otherwise.
+type ExtendedKey struct {
+	Depth       uint8
+	ParentFP    [4]byte
+	ChildNumber uint32
+	ChainCode   [32]byte
+	PubKey      cipher.PubKey
+	SecKey      cipher.SecKey
+	Private     bool
+}
+
+// NewMasterKey creates the BIP32 master key of a seed.
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+	mac := hmac.New(sha512.New, []byte(hdMasterKey))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	k := &ExtendedKey{Private: true}
+	if err := k.setSecKey(sum[:32]); err != nil {
+		return nil, err
+	}
+	copy(k.ChainCode[:], sum[32:])
+	return k, nil
+}
+
+// Child derives the child extended key of index i. Indexes from
+// HardenedKeyStart are hardened, and can only be derived from private keys.
+func (k *ExtendedKey) Child(i uint32) (*ExtendedKey, error) {
+	hardened := i >= HardenedKeyStart
+	if hardened && !k.Private {
+		return nil, ErrHardenedFromPublic
+	}
+
+	var data []byte
+	if hardened {
+		data = append([]byte{0}, k.SecKey[:]...)
+	} else {
+		data = append([]byte{}, k.PubKey[:]...)
+	}
+	data = append(data, uint32Bytes(i)...)
+
+	mac := hmac.New(sha512.New, k.ChainCode[:])
+	mac.Write(data)
+	sum := mac.Sum(nil)
+	il, ir := sum[:32], sum[32:]
+	if new(big.Int).SetBytes(il).Cmp(curveOrder) >= 0 {
+		return nil, ErrInvalidChild
+	}
+
+	child := &ExtendedKey{
+		Depth:       k.Depth + 1,
+		ChildNumber: i,
+		Private:     k.Private,
+	}
+	copy(child.ParentFP[:], k.fingerprint())
+	copy(child.ChainCode[:], ir)
+
+	if k.Private {
+		n := new(big.Int).SetBytes(il)
+		n.Add(n, new(big.Int).SetBytes(k.SecKey[:]))
+		n.Mod(n, curveOrder)
+		if n.Sign() == 0 {
+			return nil, ErrInvalidChild
+		}
+		if err := child.setSecKey(n.Bytes()); err != nil {
+			return nil, err
+		}
+	} else {
+		pk := secp.BaseMultiplyAdd(k.PubKey[:], il)
+		if pk == nil {
+			return nil, ErrInvalidChild
+		}
+		copy(child.PubKey[:], pk)
+	}
+	return child, nil
+}
+
+// Derive derives the descendant extended key of a path of indexes.
+func (k *ExtendedKey) Derive(path ...uint32) (*ExtendedKey, error) {
+	var err error
+	for _, i := range path {
+		if k, err = k.Child(i); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// Neuter obtains the public extended key of a private extended key.
+func (k *ExtendedKey) Neuter() *ExtendedKey {
+	out := *k
+	out.SecKey = cipher.SecKey{}
+	out.Private = false
+	return &out
+}
+
+// String encodes the extended key in base58 (as 'xprv...' or 'xpub...').
+func (k *ExtendedKey) String() string {
+	b := make([]byte, 0, extendedKeySize+4)
+	if k.Private {
+		b = append(b, xprvVersion...)
+	} else {
+		b = append(b, xpubVersion...)
+	}
+	b = append(b, k.Depth)
+	b = append(b, k.ParentFP[:]...)
+	b = append(b, uint32Bytes(k.ChildNumber)...)
+	b = append(b, k.ChainCode[:]...)
+	if k.Private {
+		b = append(b, 0)
+		b = append(b, k.SecKey[:]...)
+	} else {
+		b = append(b, k.PubKey[:]...)
+	}
+	sum := cipher.DoubleSHA256(b)
+	return string(base58.Hex2Base58(append(b, sum[:4]...)))
+}
+
+// ParseExtendedKey decodes a base58 encoded extended key.
+func ParseExtendedKey(s string) (*ExtendedKey, error) {
+	n, err := base58.Base58(s).ToBig()
+	if err != nil {
+		return nil, ErrInvalidExtendedKey
+	}
+	b := n.Bytes()
+	if len(b) != extendedKeySize+4 {
+		return nil, ErrInvalidExtendedKey
+	}
+	b, check := b[:extendedKeySize], b[extendedKeySize:]
+	if sum := cipher.DoubleSHA256(b); !bytes.Equal(sum[:4], check) {
+		return nil, ErrInvalidExtendedKey
+	}
+
+	k := &ExtendedKey{
+		Depth:       b[4],
+		ChildNumber: binary.BigEndian.Uint32(b[9:13]),
+	}
+	copy(k.ParentFP[:], b[5:9])
+	copy(k.ChainCode[:], b[13:45])
+
+	switch version, key := b[:4], b[45:]; {
+	case bytes.Equal(version, xprvVersion) && key[0] == 0:
+		k.Private = true
+		if err := k.setSecKey(key[1:]); err != nil {
+			return nil, err
+		}
+	case bytes.Equal(version, xpubVersion):
+		copy(k.PubKey[:], key)
+		if err := k.PubKey.Verify(); err != nil {
+			return nil, ErrInvalidExtendedKey
+		}
+	default:
+		return nil, ErrInvalidExtendedKey
+	}
+	return k, nil
+}
+
+func (k *ExtendedKey) setSecKey(b []byte) error {
+	copy(k.SecKey[32-len(b):], b)
+	if err := k.SecKey.Verify(); err != nil {
+		return ErrInvalidChild
+	}
+	k.PubKey = cipher.PubKeyFromSecKey(k.SecKey)
+	return nil
+}
+
+// fingerprint is the first 4 bytes of the HASH160 of the public key.
+func (k *ExtendedKey) fingerprint() []byte {
+	sum := cipher.SumSHA256(k.PubKey[:])
+	rd := cipher.HashRipemd160(sum[:])
+	return rd[:4]
+}
+
+/*
+	<<< BIP44 >>>
+*/
+
+// HDAccountPath is the BIP44 path of an account: m/44'/8000'/account'.
+func HDAccountPath(account uint32) []uint32 {
+	return []uint32{
+		HDPurpose + HardenedKeyStart,
+		HDCoinType + HardenedKeyStart,
+		account + HardenedKeyStart,
+	}
+}
+
+// FormatHDPath formats a path of indexes, such as "m/44'/8000'/0'/0".
+func FormatHDPath(path []uint32) string {
+	out := "m"
+	for _, i := range path {
+		if i >= HardenedKeyStart {
+			out += fmt.Sprintf("/%d'", i-HardenedKeyStart)
+		} else {
+			out += fmt.Sprintf("/%d", i)
+		}
+	}
+	return out
+}
+
+// ParseHDPath parses a path of indexes, such as "m/44'/8000'/0'/0".
+func ParseHDPath(s string) ([]uint32, error) {
+	parts := strings.Split(s, "/")
+	if parts[0] != "m" {
+		return nil, fmt.Errorf("invalid derivation path '%s'", s)
+	}
+	path := make([]uint32, len(parts)-1)
+	for i, part := range parts[1:] {
+		var offset uint32
+		if strings.HasSuffix(part, "'") {
+			part, offset = strings.TrimSuffix(part, "'"), HardenedKeyStart
+		}
+		index, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || uint32(index) >= HardenedKeyStart {
+			return nil, fmt.Errorf("invalid derivation path '%s'", s)
+		}
+		path[i] = uint32(index) + offset
+	}
+	return path, nil
+}
+
+// NewHDAccountKey derives the private extended key of a BIP44 account from
+// the seed.
+func NewHDAccountKey(seed []byte, account uint32) (*ExtendedKey, error) {
+	if account >= HardenedKeyStart {
+		return nil, fmt.Errorf("invalid account index %d", account)
+	}
+	master, err := NewMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	return master.Derive(HDAccountPath(account)...)
+}
+
+// HDChainKeys derives n keys of a chain of an account extended key, starting
+// at index start. Indexes of invalid children are skipped, as BIP32
+// recommends, so the returned indexes are not always contiguous.
+func HDChainKeys(account *ExtendedKey, chain uint32, start uint32, n int) ([]*ExtendedKey, error) {
+	chainKey, err := account.Child(chain)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*ExtendedKey, 0, n)
+	for i := start; len(out) < n; i++ {
+		if i >= HardenedKeyStart {
+			return nil, errors.New("no more child keys in chain")
+		}
+		k, err := chainKey.Child(i)
+		switch err {
+		case nil:
+			out = append(out, k)
+		case ErrInvalidChild:
+			continue
+		default:
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// HDAddresses derives n addresses of a chain of an account's public (or
+// private) extended key, starting at index start. This lets a watch-only
+// service derive the addresses of an exported xpub.
+func HDAddresses(xpub string, chain uint32, start uint32, n int) ([]cipher.Address, error) {
+	account, err := ParseExtendedKey(xpub)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := HDChainKeys(account, chain, start, n)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]cipher.Address, len(keys))
+	for i, k := range keys {
+		out[i] = cipher.AddressFromPubKey(k.PubKey)
+	}
+	return out, nil
+}
+
+func uint32Bytes(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
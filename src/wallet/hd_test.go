@@ -0,0 +1,72 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/stretchr/testify/require"
+)
+
+// BIP32 test vector 1.
+func TestExtendedKey_Derive(t *testing.T) {
+	seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	require.NoError(t, err)
+	master, err := NewMasterKey(seed)
+	require.NoError(t, err)
+
+	cases := []struct {
+		Path string
+		XPub string
+		XPrv string
+	}{
+		{
+			Path: "m",
+			XPub: "xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8",
+			XPrv: "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi",
+		},
+		{
+			Path: "m/0'",
+			XPub: "xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw",
+			XPrv: "xprv9uHRZZhk6KAJC1avXpDAp4MDc3sQKNxDiPvvkX8Br5ngLNv1TxvUxt4cV1rGL5hj6KCesnDYUhd7oWgT11eZG7XnxHrnYeSvkzY7d2bhkJ7",
+		},
+		{
+			Path: "m/0'/1",
+			XPub: "xpub6ASuArnXKPbfEwhqN6e3mwBcDTgzisQN1wXN9BJcM47sSikHjJf3UFHKkNAWbWMiGj7Wf5uMash7SyYq527Hqck2AxYysAA7xmALppuCkwQ",
+			XPrv: "xprv9wTYmMFdV23N2TdNG573QoEsfRrWKQgWeibmLntzniatZvR9BmLnvSxqu53Kw1UmYPxLgboyZQaXwTCg8MSY3H2EU4pWcQDnRnrVA1xe8fs",
+		},
+	}
+	for _, c := range cases {
+		path, err := ParseHDPath(c.Path)
+		require.NoError(t, err)
+		require.Equal(t, c.Path, FormatHDPath(path))
+
+		k, err := master.Derive(path...)
+		require.NoError(t, err, c.Path)
+		require.Equal(t, c.XPrv, k.String(), c.Path)
+		require.Equal(t, c.XPub, k.Neuter().String(), c.Path)
+
+		parsed, err := ParseExtendedKey(c.XPub)
+		require.NoError(t, err)
+		require.Equal(t, k.Neuter(), parsed)
+		parsed, err = ParseExtendedKey(c.XPrv)
+		require.NoError(t, err)
+		require.Equal(t, k, parsed)
+	}
+
+	// Public derivation matches private derivation.
+	account, err := NewHDAccountKey(seed, 0)
+	require.NoError(t, err)
+	priv, err := HDChainKeys(account, ChangeChain, 0, 3)
+	require.NoError(t, err)
+	addrs, err := HDAddresses(account.Neuter().String(), ChangeChain, 0, 3)
+	require.NoError(t, err)
+	for i, k := range priv {
+		require.Equal(t, cipher.AddressFromSecKey(k.SecKey), addrs[i])
+	}
+
+	_, err = account.Neuter().Child(HardenedKeyStart)
+	require.Equal(t, ErrHardenedFromPublic, err)
+	_, err = ParseExtendedKey("xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet9")
+	require.Equal(t, ErrInvalidExtendedKey, err)
+}
@@ -0,0 +1,93 @@
+package wallet
+
+import (
+	"errors"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+	"golang.org/x/crypto/scrypt"
+)
+
+var (
+	ErrInvalidKDFParams = errors.New("wallet file has invalid key derivation parameters")
+)
+
+const (
+	KDFSaltSize   = 32
+	KDFKeySize    = 32
+	KDFParamsSize = KDFSaltSize + 3*8
+
+	// DefaultScryptN is the CPU/memory cost of newly saved wallet files.
+	DefaultScryptN = 1 << 15
+	// DefaultScryptR is the block size of newly saved wallet files.
+	DefaultScryptR = 8
+	// DefaultScryptP is the parallelization of newly saved wallet files.
+	DefaultScryptP = 1
+)
+
+// scryptN is the CPU/memory cost used by NewKDFParams.
+// Tests lower it, as the default is slow under the race detector.
+var scryptN uint64 = DefaultScryptN
+
+// KDFParams are the scrypt parameters used to derive a wallet file's
+// encryption key from it's password. They are stored in the file header,
+// directly after the Prefix.
+type KDFParams struct {
+	Salt [KDFSaltSize]byte
+	N    uint64
+	R    uint64
+	P    uint64
+}
+
+// NewKDFParams creates KDFParams with a random salt and default costs.
+func NewKDFParams() KDFParams {
+	var p = KDFParams{
+		N: scryptN,
+		R: DefaultScryptR,
+		P: DefaultScryptP,
+	}
+	copy(p.Salt[:], cipher.RandByte(KDFSaltSize))
+	return p
+}
+
+// ExtractKDFParams reads the KDFParams from the start of the data that
+// follows a wallet file's Prefix.
+func ExtractKDFParams(data []byte) (KDFParams, []byte, error) {
+	var p KDFParams
+	if len(data) < KDFParamsSize {
+		return p, nil, ErrFileSize
+	}
+	if err := encoder.DeserializeRaw(data[:KDFParamsSize], &p); err != nil {
+		return p, nil, err
+	}
+	if err := p.Verify(); err != nil {
+		return p, nil, err
+	}
+	return p, data[KDFParamsSize:], nil
+}
+
+// Verify checks that the parameters are accepted by scrypt.
+func (p KDFParams) Verify() error {
+	if p.N <= 1 || p.N&(p.N-1) != 0 || p.R == 0 || p.P == 0 ||
+		p.N > 1<<30 || p.R*p.P >= 1<<30 {
+		return ErrInvalidKDFParams
+	}
+	return nil
+}
+
+// Serialize encodes the parameters for the wallet file header.
+func (p KDFParams) Serialize() []byte {
+	return encoder.Serialize(p)
+}
+
+// Key derives the encryption key from the password.
+func (p KDFParams) Key(password string) ([]byte, error) {
+	return scrypt.Key([]byte(password), p.Salt[:],
+		int(p.N), int(p.R), int(p.P), KDFKeySize)
+}
+
+// legacyKey derives the encryption key of version 0 wallet files.
+func legacyKey(password string) []byte {
+	pHash := cipher.SumSHA256([]byte(password))
+	return pHash[:]
+}
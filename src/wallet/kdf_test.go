@@ -0,0 +1,42 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractKDFParams(t *testing.T) {
+	p := NewKDFParams()
+	raw := append(p.Serialize(), 1, 2, 3)
+	require.Len(t, raw, KDFParamsSize+3)
+
+	got, data, err := ExtractKDFParams(raw)
+	require.NoError(t, err)
+	require.Equal(t, p, got)
+	require.Equal(t, []byte{1, 2, 3}, data)
+
+	_, _, err = ExtractKDFParams(raw[:KDFParamsSize-1])
+	require.Equal(t, ErrFileSize, err)
+
+	p.N = 3
+	_, _, err = ExtractKDFParams(p.Serialize())
+	require.Equal(t, ErrInvalidKDFParams, err)
+}
+
+func TestKDFParams_Key(t *testing.T) {
+	p0, p1 := NewKDFParams(), NewKDFParams()
+	require.NotEqual(t, p0.Salt, p1.Salt)
+
+	k0, err := p0.Key("password")
+	require.NoError(t, err)
+	require.Len(t, k0, KDFKeySize)
+
+	k1, err := p0.Key("password")
+	require.NoError(t, err)
+	require.Equal(t, k0, k1)
+
+	k1, err = p1.Key("password")
+	require.NoError(t, err)
+	require.NotEqual(t, k0, k1)
+}
@@ -0,0 +1,136 @@
+package wallet
+
+import (
+	"encoding/base32"
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrLabelInvalidChars = errors.New(
+		"label can only have letters, numbers, spaces and -_.,()'&+#@, and can not start or end with a space or dot")
+	ErrLabelReserved = errors.New("label is a reserved name")
+)
+
+const (
+	// MaxLabelLength is the maximum number of characters of a wallet label.
+	MaxLabelLength = 64
+
+	// MaxLabelSize is the maximum size of a wallet label in bytes, so that
+	// the file names of labels with non-ASCII characters stay short enough
+	// for every file system.
+	MaxLabelSize = 128
+
+	// labelPunct holds the punctuation that is allowed in labels, besides
+	// spaces.
+	labelPunct = "-_.,()'&+#@"
+
+	// encodedLabelPrefix starts the file names of encoded labels. It is not
+	// allowed in labels, so encoded file names never clash with plain ones.
+	encodedLabelPrefix = "~"
+)
+
+// reservedLabels are names that Windows does not allow as file names, with
+// or without an extension.
+var reservedLabels = map[string]struct{}{
+	"con": {}, "prn": {}, "aux": {}, "nul": {},
+	"com1": {}, "com2": {}, "com3": {}, "com4": {}, "com5": {},
+	"com6": {}, "com7": {}, "com8": {}, "com9": {},
+	"lpt1": {}, "lpt2": {}, "lpt3": {}, "lpt4": {}, "lpt5": {},
+	"lpt6": {}, "lpt7": {}, "lpt8": {}, "lpt9": {},
+}
+
+// labelEncoding encodes labels that are not plain as file names. Lower case
+// base32 is used, as some file systems are case-insensitive.
+var labelEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").
+	WithPadding(base32.NoPadding)
+
+// VerifyLabel checks that a wallet label follows the label policy:
+//   - It has at most MaxLabelLength characters, and MaxLabelSize bytes.
+//   - It has only letters, numbers, spaces and the punctuation -_.,()'&+#@,
+//     and does not start or end with a space or dot.
+//   - It is not a reserved name, such as 'con' or 'lpt1'.
+//
+// Labels are compared case-insensitively by the manager, as file names are
+// on some file systems.
+func VerifyLabel(label string) error {
+	if !utf8.ValidString(label) {
+		return ErrLabelInvalidChars
+	}
+	if n := utf8.RuneCountInString(label); n == 0 || n > MaxLabelLength {
+		return ErrValueNotInRange{
+			ValName: "label",
+			ExpMin:  1,
+			ExpMax:  MaxLabelLength,
+			Got:     n,
+		}
+	}
+	if len(label) > MaxLabelSize {
+		return ErrValueNotInRange{
+			ValName: "label size",
+			ExpMin:  1,
+			ExpMax:  MaxLabelSize,
+			Got:     len(label),
+		}
+	}
+	for _, r := range label {
+		switch {
+		case unicode.IsLetter(r), unicode.IsNumber(r), unicode.IsMark(r):
+		case r == ' ', strings.ContainsRune(labelPunct, r):
+		default:
+			return ErrLabelInvalidChars
+		}
+	}
+	for _, edge := range []byte{label[0], label[len(label)-1]} {
+		if edge == ' ' || edge == '.' {
+			return ErrLabelInvalidChars
+		}
+	}
+	name := strings.ToLower(label)
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		name = name[:i]
+	}
+	if _, ok := reservedLabels[strings.TrimRight(name, " ")]; ok {
+		return ErrLabelReserved
+	}
+	return nil
+}
+
+// plainLabel returns true if the label can be used as a file name as is,
+// which is for labels of only ASCII letters, numbers, spaces and -_.
+func plainLabel(label string) bool {
+	for i := 0; i < len(label); i++ {
+		switch c := label[i]; {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == ' ', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// labelFilename obtains the file name (without extension) of a label.
+// Plain labels are used as is, and others are encoded, so that no label can
+// escape or break the wallet directory.
+func labelFilename(label string) string {
+	if plainLabel(label) {
+		return label
+	}
+	return encodedLabelPrefix + labelEncoding.EncodeToString([]byte(label))
+}
+
+// filenameLabel obtains the label of a file name (without extension). File
+// names that are not encoded are labels as is.
+func filenameLabel(name string) string {
+	if !strings.HasPrefix(name, encodedLabelPrefix) {
+		return name
+	}
+	b, err := labelEncoding.DecodeString(strings.TrimPrefix(name, encodedLabelPrefix))
+	if err != nil || !utf8.Valid(b) {
+		return name
+	}
+	return string(b)
+}
@@ -0,0 +1,60 @@
+package wallet
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyLabel(t *testing.T) {
+	cases := []struct {
+		label string
+		valid bool
+	}{
+		{"wallet0", true},
+		{"My Wallet (old)", true},
+		{"Café", true},
+		{"財布", true},
+		{"a.b", true},
+		{strings.Repeat("a", MaxLabelLength), true},
+		{"", false},
+		{strings.Repeat("a", MaxLabelLength+1), false},
+		{strings.Repeat("財", MaxLabelSize/3+1), false},
+		{"../wallet0", false},
+		{"wallet/0", false},
+		{`wallet\0`, false},
+		{"wallet\x00", false},
+		{"wallet\n", false},
+		{"wallet:0", false},
+		{"wallet*", false},
+		{"~wallet", false},
+		{".wallet", false},
+		{"wallet.", false},
+		{" wallet", false},
+		{"wallet ", false},
+		{"\xff", false},
+		{"con", false},
+		{"NUL", false},
+		{"lpt1.txt", false},
+		{"console", true},
+	}
+	for _, c := range cases {
+		err := VerifyLabel(c.label)
+		if c.valid {
+			require.NoError(t, err, c.label)
+		} else {
+			require.Error(t, err, c.label)
+		}
+	}
+}
+
+func TestLabelFilename(t *testing.T) {
+	for _, label := range []string{"wallet0", "My Wallet", "Café", "財布", "a(b)", "../x"} {
+		name := labelFilename(label)
+		require.Equal(t, label, filenameLabel(name))
+		require.False(t, strings.ContainsAny(name, "/\\:*?\"<>|"), name)
+	}
+	require.Equal(t, "wallet0", labelFilename("wallet0"))
+	require.Equal(t, "~invalid", filenameLabel("~invalid"))
+}
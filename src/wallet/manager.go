@@ -2,45 +2,140 @@ package wallet
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+
+	"github.com/watercompany/kittycash-wallet/src/wallet2"
 )
 
 var (
 	ErrWalletNotFound     = errors.New("wallet of label is not found")
 	ErrWalletLocked       = errors.New("wallet is locked")
 	ErrLabelAlreadyExists = errors.New("label already exists")
+	ErrWalletNotEncrypted = errors.New("wallet is not encrypted")
+	ErrWalletEncrypted    = errors.New("wallet is already encrypted")
+	ErrWalletQuarantined  = errors.New("wallet file can not be loaded, see the wallet diagnostics")
 )
 
 type ManagerConfig struct {
 	RootDir string
+
+	// Storage stores the wallet files, and BackupStorage their backups. If
+	// Storage is nil, the files are stored in RootDir, and their backups in
+	// it's BackupDir.
+	Storage       Storage
+	BackupStorage Storage
+
+	// LockTimeout is the duration an unlocked encrypted wallet can be idle
+	// before it is locked again. Zero disables auto-locking.
+	LockTimeout time.Duration
+
+	// Backups is the number of previous versions of each wallet file that
+	// are kept in BackupDir. Zero disables backups.
+	Backups int
+
+	// WatchInterval is the interval at which the wallet files are polled for
+	// changes made outside of the manager, such as files copied into RootDir.
+	// Zero disables watching.
+	WatchInterval time.Duration
 }
 
 func (mc *ManagerConfig) Process() error {
-	var err error
-	if mc.RootDir, err = filepath.Abs(mc.RootDir); err != nil {
-		return err
+	if mc.LockTimeout < 0 {
+		return errors.New("lock timeout can not be negative")
 	}
-	if err = os.MkdirAll(mc.RootDir, os.FileMode(0700)); err != nil {
-		return err
+	if mc.Backups < 0 {
+		return errors.New("number of backups can not be negative")
+	}
+	if mc.WatchInterval < 0 {
+		return errors.New("watch interval can not be negative")
+	}
+	if mc.Storage == nil {
+		var err error
+		if mc.RootDir, err = filepath.Abs(mc.RootDir); err != nil {
+			return err
+		}
+		if err = os.MkdirAll(mc.RootDir, os.FileMode(0700)); err != nil {
+			return err
+		}
+		mc.Storage = NewFSStorage(mc.RootDir)
+		if mc.BackupStorage == nil {
+			mc.BackupStorage = NewFSStorage(BackupDirPath(mc.RootDir))
+		}
+	}
+	if mc.BackupStorage == nil {
+		if mc.Backups > 0 {
+			return errors.New("backups need a backup storage")
+		}
+		mc.BackupStorage = NewMemoryStorage()
 	}
 	return nil
 }
 
 // Manager manages the wallet files.
+//
+// Each listed wallet has a read/write lock, which is held while the wallet is
+// decrypted, has entries derived and is saved, so that wallets do not block
+// each other. The index lock 'mux' guards the fields below, and the
+// Meta.Encrypted and lastUsed fields of listed wallets. It is only held
+// briefly, and is always acquired after a wallet's lock, never before.
 type Manager struct {
 	c       *ManagerConfig
 	mux     sync.Mutex
 	labels  []string
 	wallets map[string]*Wallet
+
+	// locks holds the lock of each listed wallet, and reserved the labels of
+	// wallets of which the files are being created.
+	locks    map[string]*sync.RWMutex
+	reserved map[string]struct{}
+
+	// unsupported holds the prefixes of wallet files that are of versions
+	// newer than supported. They are listed, but can not be opened.
+	unsupported map[string]Prefix
+
+	// quarantined holds the reasons why wallet files can not be loaded.
+	// They are listed, but can not be opened.
+	quarantined map[string]string
+
+	// badLabels holds why the labels of wallet files do not follow the label
+	// policy. Such wallets are loaded as usual, so they can be renamed.
+	badLabels map[string]string
+
+	// files holds the checksums of the wallet files as they were last read
+	// or written by the manager, so that the watcher can tell which files
+	// were changed by others.
+	files   map[string]cipher.SHA256
+	changes []Change
+	seq     uint64
+
+	// subs holds the channels of the subscribers to events, and is guarded
+	// by subMux, which is never held while acquiring other locks.
+	subMux   sync.Mutex
+	subs     map[chan Event]struct{}
+	eventSeq uint64
+
+	quit      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
 }
 
 // NewManager creates a new wallet manager.
 func NewManager(config *ManagerConfig) (*Manager, error) {
 	m := &Manager{
-		c: config,
+		c:        config,
+		locks:    make(map[string]*sync.RWMutex),
+		reserved: make(map[string]struct{}),
+		subs:     make(map[chan Event]struct{}),
+		quit:     make(chan struct{}),
 	}
 	if err := m.c.Process(); err != nil {
 		return nil, err
@@ -48,44 +143,112 @@ func NewManager(config *ManagerConfig) (*Manager, error) {
 	if err := m.Refresh(); err != nil {
 		return nil, err
 	}
+	if m.c.LockTimeout > 0 {
+		m.wg.Add(1)
+		go m.autoLock()
+	}
+	if m.c.WatchInterval > 0 {
+		m.wg.Add(1)
+		go m.watch()
+	}
 	return m, nil
 }
 
+// Close stops background routines, locks all encrypted wallets and ends
+// all subscriptions. Calling it more than once has no further effect.
+func (m *Manager) Close() {
+	m.closeOnce.Do(func() {
+		close(m.quit)
+		m.wg.Wait()
+		m.LockAll()
+		m.closeSubs()
+	})
+}
+
 // Refresh reloads the list of wallets.
-// All wallets will be locked.
+// All wallets will be locked. Unlike the watcher (see WatchInterval), which
+// only reloads changed wallet files, every wallet file is reloaded.
+// Wallet files that can not be loaded are quarantined, rather than failing
+// the whole refresh.
 func (m *Manager) Refresh() error {
+	defer m.lockAll()()
+
+	labels, err := m.c.Storage.List()
+	if err != nil {
+		return err
+	}
+	type file struct {
+		raw []byte
+		err error
+	}
+	files := make([]file, len(labels))
+	for i, label := range labels {
+		files[i].raw, files[i].err = m.c.Storage.Read(label)
+	}
+
 	defer m.lock()()
 
+	for _, w := range m.wallets {
+		if w != nil {
+			w.Erase()
+		}
+	}
 	m.labels = make([]string, 0)
 	m.wallets = make(map[string]*Wallet)
-	err := RangeLabels(m.c.RootDir, func(raw []byte, label, fPath string, prefix Prefix) error {
-		if prefix.Version() != Version {
-			log.Warningf(
-				"wallet file `%s` is of version %v, while only version %v is supported",
-				label, prefix.Version(), Version)
-			return nil
-		}
-		var wallet *Wallet
-		if prefix.Encrypted() == false {
-			var err error
-			if wallet, err = LoadWallet(raw, label, ""); err != nil {
-				return err
-			}
+	m.locks = make(map[string]*sync.RWMutex)
+	m.unsupported = make(map[string]Prefix)
+	m.quarantined = make(map[string]string)
+	m.badLabels = make(map[string]string)
+	m.files = make(map[string]cipher.SHA256)
+	for i, label := range labels {
+		// Wallets being created are appended by their creators.
+		if _, ok := m.reserved[label]; ok {
+			continue
+		}
+		err := files[i].err
+		if err == nil {
+			err = m.load(label, files[i].raw)
+		}
+		if err != nil {
+			m.quarantine(label, err)
 		}
-		m.append(label, wallet)
-		return nil
-	})
-	if err != nil {
-		return err
 	}
 	return m.sort()
 }
 
+// FileStatus determines whether a wallet file is loaded.
+type FileStatus string
+
+const (
+	// FileOK represents a loaded wallet file.
+	FileOK FileStatus = "ok"
+
+	// FileUnsupported represents a wallet file of a version newer than
+	// supported.
+	FileUnsupported FileStatus = "unsupported"
+
+	// FileQuarantined represents a wallet file that can not be loaded, such
+	// as a truncated or unreadable file.
+	FileQuarantined FileStatus = "quarantined"
+)
+
 // Stat represents a wallet when listed by 'ListWallets'.
 type Stat struct {
-	Label     string `json:"label"`
-	Encrypted bool   `json:"encrypted"`
-	Locked    *bool  `json:"locked,omitempty"`
+	Label     string     `json:"label"`
+	Encrypted bool       `json:"encrypted"`
+	Locked    *bool      `json:"locked,omitempty"`
+	Status    FileStatus `json:"status"`
+
+	// Reason is why the wallet file is quarantined.
+	Reason string `json:"reason,omitempty"`
+
+	// UnsupportedVersion is the version of a wallet file that is newer than
+	// supported, and so can not be opened.
+	UnsupportedVersion uint64 `json:"unsupported_version,omitempty"`
+
+	// LabelError is why the label of a wallet file created before the label
+	// policy does not follow it. The wallet should be renamed.
+	LabelError string `json:"label_error,omitempty"`
 }
 
 // Lists the wallets available.
@@ -99,6 +262,25 @@ func (m *Manager) ListWallets() []Stat {
 			encrypted bool
 			locked    *bool
 		)
+		if prefix, ok := m.unsupported[label]; ok {
+			out[i] = Stat{
+				Label:              label,
+				Encrypted:          prefix.Encrypted(),
+				Locked:             newBool(true),
+				Status:             FileUnsupported,
+				UnsupportedVersion: prefix.Version(),
+			}
+			continue
+		}
+		if reason, ok := m.quarantined[label]; ok {
+			out[i] = Stat{
+				Label:  label,
+				Locked: newBool(true),
+				Status: FileQuarantined,
+				Reason: reason,
+			}
+			continue
+		}
 		if fw == nil {
 			encrypted = true
 			locked = new(bool)
@@ -111,9 +293,11 @@ func (m *Manager) ListWallets() []Stat {
 			}
 		}
 		out[i] = Stat{
-			Label:     label,
-			Encrypted: encrypted,
-			Locked:    locked,
+			Label:      label,
+			Encrypted:  encrypted,
+			Locked:     locked,
+			Status:     FileOK,
+			LabelError: m.badLabels[label],
 		}
 	}
 	return out
@@ -122,14 +306,16 @@ func (m *Manager) ListWallets() []Stat {
 // NewWallet creates a new wallet (and it's associated file)
 // with specified options, and the number of addresses to generate under it.
 func (m *Manager) NewWallet(opts *Options, addresses int) error {
-	defer m.lock()()
-
 	if addresses < 0 {
-		return errors.New("can not have negative number of entries")
+		return ErrValueNotInRange{
+			ValName: "addresses",
+			HasMin:  true,
+			Got:     addresses,
+		}
 	}
 
-	if _, ok := m.wallets[opts.Label]; ok {
-		return ErrLabelAlreadyExists
+	if e := m.checkLabelFree(opts.Label); e != nil {
+		return e
 	}
 
 	fw, e := NewWallet(opts)
@@ -139,148 +325,897 @@ func (m *Manager) NewWallet(opts *Options, addresses int) error {
 	if e := fw.EnsureEntries(addresses); e != nil {
 		return e
 	}
-	if e := fw.Save(m.c.RootDir); e != nil {
+	return m.create(fw)
+}
+
+// NewWatchOnlyWallet creates a new watch-only wallet (and it's associated
+// file) with specified options, that holds the given entries.
+func (m *Manager) NewWatchOnlyWallet(opts *Options, entries []Entry) error {
+	if !opts.WatchOnly {
+		return ErrNotWatchOnly
+	}
+
+	if e := m.checkLabelFree(opts.Label); e != nil {
 		return e
 	}
-	m.append(opts.Label, fw)
-	return m.sort()
+
+	fw, e := NewWallet(opts)
+	if e != nil {
+		return e
+	}
+	if e := fw.AddWatchEntries(entries); e != nil {
+		return e
+	}
+	return m.create(fw)
+}
+
+// RestoreWallet creates a new wallet (and it's associated file) from the seed
+// of an existing wallet, with entries discovered by the checker.
+// The checker is not called with any lock held, as it may query the
+// network. The number of entries of the restored wallet is returned.
+func (m *Manager) RestoreWallet(opts *Options, gapLimit int, checker AddressChecker) (int, error) {
+	if opts.WatchOnly {
+		return 0, ErrWatchOnlySeed
+	}
+	if checker == nil {
+		return 0, errors.New("no address checker to discover entries with")
+	}
+
+	fw, e := NewWallet(opts)
+	if e != nil {
+		return 0, e
+	}
+	if e := m.checkLabelFree(opts.Label); e != nil {
+		return 0, e
+	}
+	if e := fw.Discover(gapLimit, checker); e != nil {
+		return 0, e
+	}
+	// The label may have been taken while discovering entries.
+	if e := m.create(fw); e != nil {
+		return 0, e
+	}
+	return fw.Count(), nil
+}
+
+// AddWatchEntries appends entries to a watch-only wallet.
+// Password needs to be given if the wallet is still locked.
+func (m *Manager) AddWatchEntries(label, password string, entries []Entry) error {
+	w, unlock, err := m.acquire(label, password, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	prevEntries := w.Entries
+	if err := w.AddWatchEntries(entries); err != nil {
+		return err
+	}
+	if err := m.save(w); err != nil {
+		w.Entries = prevEntries
+		return err
+	}
+	m.entriesAdded(w, 0, len(prevEntries))
+	return nil
+}
+
+// ImportKey imports a hex encoded secret key, that is not generated from the
+// wallet's seed, into a wallet.
+// Password needs to be given if the wallet is still locked.
+func (m *Manager) ImportKey(label, password, secKeyHex string) error {
+	sk, err := cipher.SecKeyFromHex(secKeyHex)
+	if err != nil {
+		return err
+	}
+	w, unlock, err := m.acquire(label, password, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	prevImported := w.Imported
+	if err := w.ImportKey(sk); err != nil {
+		return err
+	}
+	if err := m.save(w); err != nil {
+		w.Imported = prevImported
+		return err
+	}
+	m.publish(Event{
+		Type:  EventEntriesAdded,
+		Label: label,
+		Added: len(w.Imported) - len(prevImported),
+	})
+	return nil
+}
+
+// UpdateEntry updates the metadata of the entry of address of a wallet, and
+// returns the updated entry.
+// Password needs to be given if the wallet is still locked.
+func (m *Manager) UpdateEntry(label, password, address string, update *EntryUpdate) (*FloatingEntry, error) {
+	addr, err := cipher.DecodeBase58Address(address)
+	if err != nil {
+		return nil, err
+	}
+	w, unlock, err := m.acquire(label, password, true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	var prev Entry
+	if entry := w.findEntry(addr); entry != nil {
+		prev = *entry
+	}
+	entry, err := w.UpdateEntry(addr, update)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.save(w); err != nil {
+		*entry = prev
+		return nil, err
+	}
+	out := entry.ToFloating()
+	for _, e := range w.Imported {
+		if e.Address == addr {
+			out.Imported = true
+		}
+	}
+	return out, nil
 }
 
 // DeleteWallet deletes a wallet of a given label.
 func (m *Manager) DeleteWallet(label string) error {
-	defer m.lock()()
+	unlock, err := m.lockLabel(label, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
-	if m.remove(label) {
-		return os.Remove(LabelPath(m.c.RootDir, label))
+	// The file is backed up first, so that it can still be restored.
+	if err := BackupFile(m.c.Storage, m.c.BackupStorage, label, m.c.Backups); err != nil {
+		return err
+	}
+	if err := m.c.Storage.Delete(label); err != nil {
+		return err
 	}
-	return ErrWalletNotFound
+
+	m.mux.Lock()
+	m.remove(label)
+	m.mux.Unlock()
+	m.publish(Event{Type: EventDeleted, Label: label})
+	return nil
 }
 
 // RenameWallet renames a wallet.
 func (m *Manager) RenameWallet(label, newLabel string) error {
-	defer m.lock()()
+	if err := VerifyLabel(newLabel); err != nil {
+		return err
+	}
 
-	if _, ok := m.wallets[newLabel]; ok {
-		return ErrLabelAlreadyExists
+	unlock, err := m.lockLabel(label, true)
+	if err != nil {
+		return err
 	}
+	defer unlock()
 
 	fw, err := m.getWallet(label)
 	if err != nil {
 		return err
 	}
+	release, err := m.reserve(newLabel, label)
+	if err != nil {
+		return err
+	}
+	defer release()
 
+	if err := m.c.Storage.Rename(label, newLabel); err != nil {
+		return err
+	}
 	fw.Meta.Label = newLabel
-	if err := fw.Save(m.c.RootDir); err != nil {
+	if err := moveFileBackups(m.c.BackupStorage, label, newLabel); err != nil {
+		log.Warningf("failed to move backups of wallet `%s` to `%s`: %v",
+			label, newLabel, err)
+	}
+	m.track(newLabel)
+	m.publish(Event{Type: EventRenamed, Label: newLabel, From: label})
+
+	defer m.lock()()
+	m.remove(label)
+	m.append(newLabel, fw)
+	return m.sort()
+}
+
+// ListBackups lists the backups of the wallet file of label, newest first.
+// Backups of deleted wallets are kept, so the wallet does not need to exist.
+func (m *Manager) ListBackups(label string) ([]FileBackup, error) {
+	return ListFileBackups(m.c.BackupStorage, label)
+}
+
+// RestoreBackup replaces the wallet file of label with a backup of it, or
+// recreates the file if the wallet was deleted. The replaced file is backed
+// up first. A restored encrypted wallet is locked.
+func (m *Manager) RestoreBackup(label, id string) error {
+	event := Event{Type: EventReloaded, Label: label}
+	switch unlock, err := m.lockLabel(label, true); err {
+	case nil:
+		defer unlock()
+	case ErrWalletNotFound:
+		release, err := m.reserve(label, "")
+		if err != nil {
+			return err
+		}
+		defer release()
+		event.Type = EventCreated
+	default:
 		return err
 	}
 
-	if m.remove(label) {
-		if err := os.Remove(LabelPath(m.c.RootDir, label)); err != nil {
+	raw, err := ReadFileBackup(m.c.BackupStorage, label, id)
+	if err != nil {
+		return err
+	}
+	prefix, _, err := ExtractPrefix(raw)
+	if err != nil {
+		return err
+	}
+	if wallet2.Supported(prefix.Version()) && !prefix.Encrypted() {
+		if _, err := LoadWallet(raw, label, ""); err != nil {
 			return err
 		}
 	}
+	if err := BackupFile(m.c.Storage, m.c.BackupStorage, label, m.c.Backups); err != nil {
+		return err
+	}
+	if err := m.c.Storage.Write(label, raw); err != nil {
+		return err
+	}
 
-	m.append(newLabel, fw)
+	defer m.lock()()
+	if w := m.wallets[label]; w != nil {
+		w.Erase()
+	}
+	m.remove(label)
+	if err := m.load(label, raw); err != nil {
+		return err
+	}
+	m.publish(event)
 	return m.sort()
 }
 
+// ChangePassword changes the password of an encrypted wallet.
+// The old password is required, even if the wallet is unlocked.
+func (m *Manager) ChangePassword(label, oldPassword, newPassword string) error {
+	if newPassword == "" {
+		return ErrInvalidPassword
+	}
+	w, unlock, err := m.acquire(label, oldPassword, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if !w.Meta.Encrypted {
+		return ErrWalletNotEncrypted
+	}
+	if w.Meta.Password != oldPassword {
+		return ErrInvalidPassword
+	}
+
+	w.Meta.Password = newPassword
+	if err := m.save(w); err != nil {
+		w.Meta.Password = oldPassword
+		return err
+	}
+	return nil
+}
+
+// SetEncryption encrypts an unencrypted wallet with the given password, or
+// decrypts an encrypted wallet (in which case the password is required).
+func (m *Manager) SetEncryption(label, password string, encrypted bool) error {
+	if password == "" {
+		return ErrInvalidPassword
+	}
+	w, unlock, err := m.acquire(label, password, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	prevMeta, meta := w.Meta, w.Meta
+	switch {
+	case encrypted && w.Meta.Encrypted:
+		return ErrWalletEncrypted
+	case encrypted:
+		meta.Encrypted = true
+		meta.Password = password
+	case !w.Meta.Encrypted:
+		return ErrWalletNotEncrypted
+	case w.Meta.Password != password:
+		return ErrInvalidPassword
+	default:
+		meta.Encrypted = false
+		meta.Password = ""
+	}
+
+	m.setMeta(w, meta)
+	if err := m.save(w); err != nil {
+		m.setMeta(w, prevMeta)
+		return err
+	}
+	return nil
+}
+
+// Lock locks an unlocked encrypted wallet, erasing it's secrets from memory.
+// Locking an already locked wallet has no effect.
+func (m *Manager) Lock(label string) error {
+	unlock, err := m.lockLabel(label, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	switch w, err := m.getWallet(label); err {
+	case nil:
+		if !w.Meta.Encrypted {
+			return ErrWalletNotEncrypted
+		}
+		m.lockWallet(label, w)
+		return nil
+	case ErrWalletLocked:
+		return nil
+	default:
+		return err
+	}
+}
+
+// LockAll locks all unlocked encrypted wallets.
+func (m *Manager) LockAll() {
+	m.lockWhere(func(*Wallet) bool { return true })
+}
+
+// Unlock unlocks the wallet of label, and sets the BIP39 passphrase of it's
+// seed. Password needs to be given if the wallet is still locked. The
+// passphrase is needed to generate more entries of a wallet created with one,
+// and is forgotten when the wallet is locked.
+func (m *Manager) Unlock(label, password, passphrase string) error {
+	w, unlock, err := m.acquire(label, password, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return w.SetPassphrase(passphrase)
+}
+
+// ExportXPub obtains the BIP32 extended public key of an account of a wallet
+// with HDDerivation, and the account's derivation path.
+// Password needs to be given if the wallet is still locked.
+func (m *Manager) ExportXPub(label, password string, account uint32) (string, string, error) {
+	w, unlock, err := m.acquire(label, password, false)
+	if err != nil {
+		return "", "", err
+	}
+	defer unlock()
+	return w.ExtendedPublicKey(account)
+}
+
+// NewAccount adds an account of name to a wallet, with the given number of
+// addresses. Password needs to be given if the wallet is still locked.
+func (m *Manager) NewAccount(label, password, name string, addresses int) (*AccountStat, error) {
+	w, unlock, err := m.acquire(label, password, true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	prevAccounts := w.Accounts
+	stat, err := w.NewAccount(name, addresses)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.save(w); err != nil {
+		w.Accounts = prevAccounts
+		return nil, err
+	}
+	m.entriesAdded(w, stat.Index, 0)
+	return stat, nil
+}
+
+// RenameAccount renames the account of index of a wallet.
+// Password needs to be given if the wallet is still locked.
+func (m *Manager) RenameAccount(label, password string, index uint32, name string) error {
+	w, unlock, err := m.acquire(label, password, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	prevAccounts := append([]Account(nil), w.Accounts...)
+	if err := w.RenameAccount(index, name); err != nil {
+		return err
+	}
+	if err := m.save(w); err != nil {
+		w.Accounts = prevAccounts
+		return err
+	}
+	return nil
+}
+
+// ListAccounts lists the accounts of a wallet.
+// Password needs to be given if the wallet is still locked.
+func (m *Manager) ListAccounts(label, password string) ([]AccountStat, error) {
+	w, unlock, err := m.acquire(label, password, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return w.ListAccounts(), nil
+}
+
+// MigrateWallet rewrites the file of a wallet of an older version with the
+// latest Version. It returns false if the file is already of the latest
+// Version. Password needs to be given if the wallet is still locked.
+func (m *Manager) MigrateWallet(label, password string) (bool, error) {
+	w, unlock, err := m.acquire(label, password, true)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	if w.Meta.Version == Version {
+		return false, nil
+	}
+	from := w.Meta.Version
+	if err := m.save(w); err != nil {
+		return false, err
+	}
+	log.Infof("migrated wallet `%s` from version %v to %v", label, from, Version)
+	return true, nil
+}
+
+// Export creates a backup of the wallet file of label, as it is stored.
+// The backup of an encrypted wallet remains encrypted.
+func (m *Manager) Export(label string) (*Backup, error) {
+	unlock, err := m.lockLabel(label, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	raw, err := m.c.Storage.Read(label)
+	if err != nil {
+		return nil, err
+	}
+	return NewBackup(label, raw)
+}
+
+// ExportPlain creates a backup of an unencrypted copy of the wallet file of
+// label. The password of an encrypted wallet is always required, even if the
+// wallet is unlocked.
+func (m *Manager) ExportPlain(label, password string) (*Backup, error) {
+	w, unlock, err := m.acquire(label, password, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if w.Meta.Encrypted && w.Meta.Password != password {
+		return nil, ErrInvalidPassword
+	}
+	raw, err := encodeFile(w.ToFile(), false, "")
+	if err != nil {
+		return nil, err
+	}
+	return NewBackup(label, raw)
+}
+
+// Import restores a wallet from a backup, and returns the label it is
+// imported under.
+func (m *Manager) Import(r io.Reader, opts *ImportOptions) (string, error) {
+	b, raw, err := ReadBackup(r)
+	if err != nil {
+		return "", err
+	}
+
+	label := opts.Label
+	if label == "" {
+		label = b.Label
+	}
+	m.mux.Lock()
+	if m.hasLabel(label) && opts.Rename {
+		label = m.freeLabel(label)
+	}
+	m.mux.Unlock()
+	if err := VerifyLabel(label); err != nil {
+		return "", err
+	}
+	release, err := m.reserve(label, "")
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	// A file of the label may exist that is not listed yet, such as one
+	// written by another process since the watcher last polled.
+	if _, err := m.c.Storage.Read(label); err == nil {
+		return "", ErrLabelAlreadyExists
+	} else if err != ErrFileNotFound {
+		return "", err
+	}
+
+	// Encrypted wallets are only decoded if a password is given, otherwise
+	// they are imported locked.
+	var w *Wallet
+	if !b.Encrypted || opts.Password != "" {
+		if w, err = LoadWallet(raw, label, opts.Password); err != nil {
+			return "", err
+		}
+		w.lastUsed = time.Now()
+	}
+	if err := m.c.Storage.Write(label, raw); err != nil {
+		return "", err
+	}
+
+	defer m.lock()()
+	m.files[label] = cipher.SumSHA256(raw)
+	m.append(label, w)
+	m.publish(Event{Type: EventCreated, Label: label})
+	return label, m.sort()
+}
+
 // DisplayWallet displays the wallet of specified label.
 // Password needs to be given if a wallet is still locked.
 // Addresses ensures that wallet has at least the number of address entries.
 func (m *Manager) DisplayWallet(label, password string, addresses int) (*FloatingWallet, error) {
-	defer m.lock()()
+	w, unlock, err := m.acquire(label, password, true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
-	switch w, err := m.getWallet(label); err {
-	case nil:
-		if err := w.EnsureEntries(addresses); err != nil {
+	prev := w.Count()
+	if err := w.EnsureEntries(addresses); err != nil {
+		return nil, err
+	}
+	if !w.Meta.Saved {
+		if err := m.save(w); err != nil {
+			return nil, err
+		}
+	}
+	m.entriesAdded(w, 0, prev)
+	return w.ToFloating(), nil
+}
+
+// ExportSecrets displays the wallet of specified label, including it's seed
+// and secret keys. The password of an encrypted wallet is always required,
+// even if the wallet is unlocked.
+func (m *Manager) ExportSecrets(label, password string) (*SecretFloatingWallet, error) {
+	w, unlock, err := m.acquire(label, password, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if w.Meta.Encrypted && w.Meta.Password != password {
+		return nil, ErrInvalidPassword
+	}
+	if w.IsWatchOnly() {
+		return nil, ErrWatchOnly
+	}
+	return w.ToSecretFloating(), nil
+}
+
+// SecKey obtains the secret key of the entry of address, to sign with.
+// ErrWatchOnly is returned for watch-only wallets, which hold no secret keys.
+func (m *Manager) SecKey(label, password, address string) (cipher.SecKey, error) {
+	addr, err := cipher.DecodeBase58Address(address)
+	if err != nil {
+		return cipher.SecKey{}, err
+	}
+	w, unlock, err := m.acquire(label, password, false)
+	if err != nil {
+		return cipher.SecKey{}, err
+	}
+	defer unlock()
+	if w.IsWatchOnly() {
+		return cipher.SecKey{}, ErrWatchOnly
+	}
+	entry := w.findEntry(addr)
+	if entry == nil {
+		return cipher.SecKey{}, ErrEntryNotFound
+	}
+	return entry.SecKey, nil
+}
+
+// DisplayPaginatedWallet displays a page of the entries of an account of a
+// wallet. If forceTotal is not -1, the account is ensured to have that many
+// entries first.
+func (m *Manager) DisplayPaginatedWallet(label, password string, account uint32, startIndex, pageSize, forceTotal int) (*PaginatedFloatingWallet, error) {
+	w, unlock, err := m.acquire(label, password, true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if forceTotal != -1 {
+		prev := w.accountCount(account)
+		if err := w.EnsureAccountEntries(account, forceTotal); err != nil {
 			return nil, err
 		}
 		if !w.Meta.Saved {
-			if err := w.Save(m.c.RootDir); err != nil {
+			if err := m.save(w); err != nil {
 				return nil, err
 			}
 		}
-		return w.ToFloating(), nil
+		m.entriesAdded(w, account, prev)
+	}
+	return w.ToPaginatedFloating(account, startIndex, pageSize)
+}
 
-	case ErrWalletNotFound:
-		return nil, ErrWalletNotFound
+/*
+	<<< HELPER FUNCTIONS >>>
+*/
 
-	case ErrWalletLocked:
-		raw, err := OpenAndReadAll(LabelPath(m.c.RootDir, label))
-		if err != nil {
-			return nil, err
+// lock acquires the index lock.
+func (m *Manager) lock() func() {
+	m.mux.Lock()
+	return m.mux.Unlock
+}
+
+// lockLabel acquires the lock of the wallet of label, for writing or only for
+// reading. ErrWalletNotFound is returned if the wallet is not listed, or is
+// removed while waiting for the lock.
+func (m *Manager) lockLabel(label string, write bool) (func(), error) {
+	for {
+		m.mux.Lock()
+		l, ok := m.locks[label]
+		m.mux.Unlock()
+		if !ok {
+			return nil, ErrWalletNotFound
 		}
-		if w, err = LoadWallet(raw, label, password); err != nil {
-			return nil, err
+		unlock := l.RUnlock
+		if write {
+			l.Lock()
+			unlock = l.Unlock
+		} else {
+			l.RLock()
 		}
-		m.wallets[label] = w
-		if err := w.EnsureEntries(addresses); err != nil {
-			return nil, err
+
+		// The wallet may have been removed or reloaded while waiting.
+		m.mux.Lock()
+		current := m.locks[label] == l
+		m.mux.Unlock()
+		if current {
+			return unlock, nil
 		}
-		if !w.Meta.Saved {
-			if err := w.Save(m.c.RootDir); err != nil {
-				return nil, err
+		unlock()
+	}
+}
+
+// lockAll acquires the write locks of all listed wallets, in the order of
+// their labels.
+func (m *Manager) lockAll() func() {
+	for {
+		m.mux.Lock()
+		locks := make([]*sync.RWMutex, len(m.labels))
+		for i, label := range m.labels {
+			locks[i] = m.locks[label]
+		}
+		m.mux.Unlock()
+
+		for _, l := range locks {
+			l.Lock()
+		}
+		unlock := func() {
+			for _, l := range locks {
+				l.Unlock()
 			}
 		}
-		return w.ToFloating(), nil
 
-	default:
-		return nil, errors.New("unknown error")
+		// Wallets may have been added or removed while waiting.
+		m.mux.Lock()
+		current := len(locks) == len(m.labels)
+		for i := 0; current && i < len(locks); i++ {
+			current = m.locks[m.labels[i]] == locks[i]
+		}
+		m.mux.Unlock()
+		if current {
+			return unlock
+		}
+		unlock()
 	}
 }
 
-func (m *Manager) DisplayPaginatedWallet(label, password string, startIndex, pageSize, forceTotal int) (*PaginatedFloatingWallet, error) {
+// acquire locks the wallet of label (see lockLabel), and obtains it,
+// decrypting it with the given password if it is still locked. As decrypting
+// a wallet needs it's write lock, the write lock is acquired instead of the
+// read lock for locked wallets.
+func (m *Manager) acquire(label, password string, write bool) (*Wallet, func(), error) {
+	unlock, err := m.lockLabel(label, write)
+	if err != nil {
+		return nil, nil, err
+	}
+	w, err := m.getWallet(label)
+	if err == ErrWalletLocked {
+		if !write {
+			unlock()
+			return m.acquire(label, password, true)
+		}
+		w, err = m.unlockWallet(label, password)
+	}
+	if err != nil {
+		unlock()
+		return nil, nil, err
+	}
+	return w, unlock, nil
+}
+
+// reserve reserves the label of a wallet that is being created, or renamed
+// from the label 'from', so that no other wallet can take it while it's file
+// is written. The returned function releases the label.
+func (m *Manager) reserve(label, from string) (func(), error) {
 	defer m.lock()()
 
-	toPaginatedTotal := func(w *Wallet, startIndex, pageSize, forceTotal int) (*PaginatedFloatingWallet, error) {
-		if forceTotal != -1 {
-			if err := w.EnsureEntries(forceTotal); err != nil {
-				return nil, err
-			}
-			if !w.Meta.Saved {
-				if err := w.Save(m.c.RootDir); err != nil {
-					return nil, err
-				}
-			}
+	if _, ok := m.wallets[label]; ok {
+		return nil, ErrLabelAlreadyExists
+	}
+	// Only the case of the label may change when renaming.
+	if (from == "" || !strings.EqualFold(label, from)) && m.hasLabel(label) {
+		return nil, ErrLabelAlreadyExists
+	}
+	m.reserved[label] = struct{}{}
+	return func() {
+		defer m.lock()()
+		delete(m.reserved, label)
+	}, nil
+}
+
+// create saves the file of a new wallet, and appends it.
+func (m *Manager) create(w *Wallet) error {
+	release, err := m.reserve(w.Meta.Label, "")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := m.save(w); err != nil {
+		return err
+	}
+
+	defer m.lock()()
+	m.append(w.Meta.Label, w)
+	m.publish(Event{Type: EventCreated, Label: w.Meta.Label})
+	return m.sort()
+}
+
+// setMeta sets the meta of a listed wallet, of which Encrypted is guarded by
+// the index lock.
+func (m *Manager) setMeta(w *Wallet, meta FloatingMeta) {
+	defer m.lock()()
+	w.Meta = meta
+}
+
+// autoLock locks encrypted wallets that are idle for longer than the
+// configured LockTimeout.
+func (m *Manager) autoLock() {
+	defer m.wg.Done()
+
+	interval := m.c.LockTimeout / 4
+	if interval > time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.quit:
+			return
+		case now := <-ticker.C:
+			m.lockIdle(now)
 		}
-		return w.ToPaginatedFloating(startIndex, pageSize)
 	}
+}
 
-	switch w, err := m.getWallet(label); err {
-	case nil:
-		return toPaginatedTotal(w, startIndex, pageSize, forceTotal)
+func (m *Manager) lockIdle(now time.Time) {
+	for _, label := range m.lockWhere(func(w *Wallet) bool {
+		return now.Sub(w.lastUsed) >= m.c.LockTimeout
+	}) {
+		log.Infof("wallet `%s` was idle, and is locked", label)
+	}
+}
 
-	case ErrWalletNotFound:
-		return nil, ErrWalletNotFound
+// lockWhere locks the unlocked encrypted wallets that match, and returns
+// their labels. Match is called with the index lock held.
+func (m *Manager) lockWhere(match func(w *Wallet) bool) []string {
+	matches := func(label string) (*Wallet, bool) {
+		w := m.wallets[label]
+		return w, w != nil && w.Meta.Encrypted && match(w)
+	}
 
-	case ErrWalletLocked:
-		raw, err := OpenAndReadAll(LabelPath(m.c.RootDir, label))
+	m.mux.Lock()
+	var labels []string
+	for label := range m.wallets {
+		if _, ok := matches(label); ok {
+			labels = append(labels, label)
+		}
+	}
+	m.mux.Unlock()
+
+	var out []string
+	for _, label := range labels {
+		unlock, err := m.lockLabel(label, true)
 		if err != nil {
-			return nil, err
+			continue
 		}
-		if w, err = LoadWallet(raw, label, password); err != nil {
-			return nil, err
+		m.mux.Lock()
+		w, ok := matches(label)
+		m.mux.Unlock()
+		if ok {
+			m.lockWallet(label, w)
+			out = append(out, label)
 		}
-		m.wallets[label] = w
-		return toPaginatedTotal(w, startIndex, pageSize, forceTotal)
+		unlock()
+	}
+	return out
+}
 
-	default:
-		return nil, errors.New("unknown error")
+// lockWallet erases an unlocked wallet. The wallet's write lock must be held.
+func (m *Manager) lockWallet(label string, w *Wallet) {
+	m.mux.Lock()
+	m.wallets[label] = nil
+	m.mux.Unlock()
+	w.Erase()
+	m.publish(Event{Type: EventLocked, Label: label})
+}
+
+// save backs up the wallet's file, and saves the wallet. The wallet's write
+// lock (or reservation) must be held.
+func (m *Manager) save(w *Wallet) error {
+	if err := BackupFile(m.c.Storage, m.c.BackupStorage, w.Meta.Label, m.c.Backups); err != nil {
+		return err
+	}
+	if err := w.Save(m.c.Storage); err != nil {
+		return err
 	}
+	m.track(w.Meta.Label)
+	return nil
 }
 
-/*
-	<<< HELPER FUNCTIONS >>>
-*/
+// load appends the wallet of a wallet file's raw data. Encrypted wallets are
+// appended locked, and wallets of unsupported versions can not be opened.
+// Files of labels that do not follow the label policy are not loaded.
+// The index lock must be held, as for all the helpers below.
+func (m *Manager) load(label string, raw []byte) error {
+	m.files[label] = cipher.SumSHA256(raw)
+	if err := VerifyLabel(label); err != nil {
+		log.Warningf("wallet file `%s` should be renamed: %v", label, err)
+		m.badLabels[label] = err.Error()
+	}
+	prefix, _, err := ExtractPrefix(raw)
+	if err != nil {
+		return err
+	}
+	if !wallet2.Supported(prefix.Version()) {
+		log.Warningf(
+			"wallet file `%s` is of version %v, while only versions up to %v are supported",
+			label, prefix.Version(), Version)
+		m.append(label, nil)
+		m.unsupported[label] = prefix
+		return nil
+	}
+	var wallet *Wallet
+	if prefix.Encrypted() == false {
+		if wallet, err = LoadWallet(raw, label, ""); err != nil {
+			return err
+		}
+	}
+	m.append(label, wallet)
+	return nil
+}
 
-func (m *Manager) lock() func() {
-	m.mux.Lock()
-	return m.mux.Unlock
+// quarantine appends the wallet file of label, that can not be loaded for
+// the given reason.
+func (m *Manager) quarantine(label string, reason error) {
+	log.Warningf("wallet file `%s` is quarantined: %v", label, reason)
+	m.append(label, nil)
+	m.quarantined[label] = reason.Error()
 }
 
 func (m *Manager) append(label string, fw *Wallet) {
 	m.labels = append(m.labels, label)
 	m.wallets[label] = fw
+	m.locks[label] = new(sync.RWMutex)
 }
 
 func (m *Manager) remove(label string) bool {
@@ -288,24 +1223,103 @@ func (m *Manager) remove(label string) bool {
 		if l == label {
 			m.labels = append(m.labels[:i], m.labels[i+1:]...)
 			delete(m.wallets, label)
+			delete(m.unsupported, label)
+			delete(m.quarantined, label)
+			delete(m.badLabels, label)
+			delete(m.files, label)
+			delete(m.locks, label)
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Manager) checkLabelFree(label string) error {
+	defer m.lock()()
+
+	if m.hasLabel(label) {
+		return ErrLabelAlreadyExists
+	}
+	return nil
+}
+
+// freeLabel finds an unused label by appending a number to the given label.
+func (m *Manager) freeLabel(label string) string {
+	for i := 1; ; i++ {
+		l := fmt.Sprintf("%s_%d", label, i)
+		if !m.hasLabel(l) {
+			return l
+		}
+	}
+}
+
+// hasLabel returns true if there is a wallet of label, or one is being
+// created, ignoring case, as file names do on some file systems.
+func (m *Manager) hasLabel(label string) bool {
+	for _, l := range m.labels {
+		if strings.EqualFold(l, label) {
+			return true
+		}
+	}
+	for l := range m.reserved {
+		if strings.EqualFold(l, label) {
 			return true
 		}
 	}
 	return false
 }
 
+func newBool(v bool) *bool {
+	return &v
+}
+
 func (m *Manager) sort() error {
 	sort.Strings(m.labels)
 	return nil
 }
 
+// getWallet obtains the wallet of label, if it is unlocked. Unlike the
+// helpers above, it acquires the index lock itself.
 func (m *Manager) getWallet(label string) (*Wallet, error) {
+	defer m.lock()()
+
 	w, ok := m.wallets[label]
 	if !ok {
 		return nil, ErrWalletNotFound
 	}
+	if _, ok := m.unsupported[label]; ok {
+		return nil, ErrUnsupportedVersion
+	}
+	if _, ok := m.quarantined[label]; ok {
+		return nil, ErrWalletQuarantined
+	}
 	if w == nil {
 		return nil, ErrWalletLocked
 	}
+	w.lastUsed = time.Now()
+	return w, nil
+}
+
+// unlockWallet obtains the wallet of label, decrypting it from file with the
+// given password if it is still locked. The wallet's write lock must be held,
+// and the index lock is acquired only once the wallet is decrypted.
+func (m *Manager) unlockWallet(label, password string) (*Wallet, error) {
+	w, err := m.getWallet(label)
+	if err != ErrWalletLocked {
+		return w, err
+	}
+	raw, err := m.c.Storage.Read(label)
+	if err != nil {
+		return nil, err
+	}
+	if w, err = LoadWallet(raw, label, password); err != nil {
+		return nil, err
+	}
+	w.lastUsed = time.Now()
+
+	m.mux.Lock()
+	m.wallets[label] = w
+	m.mux.Unlock()
+	m.publish(Event{Type: EventUnlocked, Label: label})
 	return w, nil
 }
@@ -0,0 +1,1091 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestManager(t *testing.T) (*Manager, func()) {
+	initTestStorage()
+	m, err := NewManager(&ManagerConfig{Storage: testStorage})
+	require.NoError(t, err)
+	return m, m.Close
+}
+
+func TestManager_ChangePassword(t *testing.T) {
+	m, done := newTestManager(t)
+	defer done()
+
+	require.NoError(t, m.NewWallet(&Options{
+		Label:     "wallet0",
+		Seed:      testSeed,
+		Encrypted: true,
+		Password:  "password",
+	}, 2))
+	require.NoError(t, m.NewWallet(&Options{
+		Label: "wallet1",
+		Seed:  testSeed,
+	}, 2))
+
+	require.Equal(t, ErrInvalidPassword,
+		m.ChangePassword("wallet0", "wrong", "new password"))
+	require.Equal(t, ErrWalletNotEncrypted,
+		m.ChangePassword("wallet1", "", "new password"))
+	require.Equal(t, ErrWalletNotFound,
+		m.ChangePassword("wallet2", "password", "new password"))
+
+	require.NoError(t, m.ChangePassword("wallet0", "password", "new password"))
+	require.NoError(t, m.Refresh())
+
+	_, err := m.DisplayWallet("wallet0", "password", 0)
+	require.Equal(t, ErrInvalidPassword, err)
+
+	fw, err := m.DisplayWallet("wallet0", "new password", 0)
+	require.NoError(t, err)
+	require.Len(t, fw.Entries, 2)
+}
+
+func TestManager_SetEncryption(t *testing.T) {
+	m, done := newTestManager(t)
+	defer done()
+
+	require.NoError(t, m.NewWallet(&Options{
+		Label: "wallet0",
+		Seed:  testSeed,
+	}, 2))
+
+	require.Equal(t, ErrWalletNotEncrypted,
+		m.SetEncryption("wallet0", "password", false))
+	require.NoError(t, m.SetEncryption("wallet0", "password", true))
+	require.Equal(t, ErrWalletEncrypted,
+		m.SetEncryption("wallet0", "password", true))
+
+	require.NoError(t, m.Refresh())
+	require.Equal(t, []Stat{{Label: "wallet0", Encrypted: true, Locked: newBool(true), Status: FileOK}},
+		m.ListWallets())
+
+	require.Equal(t, ErrInvalidPassword,
+		m.SetEncryption("wallet0", "wrong", false))
+	require.NoError(t, m.SetEncryption("wallet0", "password", false))
+
+	require.NoError(t, m.Refresh())
+	require.Equal(t, []Stat{{Label: "wallet0", Encrypted: false, Status: FileOK}},
+		m.ListWallets())
+
+	fw, err := m.DisplayWallet("wallet0", "", 0)
+	require.NoError(t, err)
+	require.Len(t, fw.Entries, 2)
+}
+
+func TestManager_Lock(t *testing.T) {
+	m, done := newTestManager(t)
+	defer done()
+
+	require.NoError(t, m.NewWallet(&Options{
+		Label:     "wallet0",
+		Seed:      testSeed,
+		Encrypted: true,
+		Password:  "password",
+	}, 2))
+	require.NoError(t, m.NewWallet(&Options{
+		Label: "wallet1",
+		Seed:  testSeed,
+	}, 2))
+
+	w := m.wallets["wallet0"]
+	require.NotNil(t, w)
+
+	require.Equal(t, ErrWalletNotEncrypted, m.Lock("wallet1"))
+	require.Equal(t, ErrWalletNotFound, m.Lock("wallet2"))
+	require.NoError(t, m.Lock("wallet0"))
+	require.NoError(t, m.Lock("wallet0"))
+
+	require.Nil(t, m.wallets["wallet0"])
+	require.Empty(t, w.Meta.Seed)
+	require.Empty(t, w.Meta.Password)
+	require.Empty(t, w.Entries)
+	require.Equal(t, []Stat{
+		{Label: "wallet0", Encrypted: true, Locked: newBool(true), Status: FileOK},
+		{Label: "wallet1", Encrypted: false, Status: FileOK},
+	}, m.ListWallets())
+
+	_, err := m.DisplayWallet("wallet0", "password", 0)
+	require.NoError(t, err)
+	require.NotNil(t, m.wallets["wallet0"])
+
+	m.LockAll()
+	require.Nil(t, m.wallets["wallet0"])
+	require.NotNil(t, m.wallets["wallet1"])
+}
+
+func TestManager_LockTimeout(t *testing.T) {
+	initTestStorage()
+
+	m, err := NewManager(&ManagerConfig{
+		Storage:     testStorage,
+		LockTimeout: 100 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer m.Close()
+
+	require.NoError(t, m.NewWallet(&Options{
+		Label:     "wallet0",
+		Seed:      testSeed,
+		Encrypted: true,
+		Password:  "password",
+	}, 2))
+	require.Equal(t, newBool(false), m.ListWallets()[0].Locked)
+
+	for deadline := time.Now().Add(time.Second); !*m.ListWallets()[0].Locked; {
+		require.True(t, time.Now().Before(deadline), "wallet should be locked")
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestManager_Passphrase(t *testing.T) {
+	m, done := newTestManager(t)
+	defer done()
+
+	require.NoError(t, m.NewWallet(&Options{
+		Label:      "wallet0",
+		Seed:       testSeed,
+		Encrypted:  true,
+		Password:   "password",
+		Passphrase: "passphrase",
+	}, 2))
+	require.NoError(t, m.NewWallet(&Options{
+		Label: "wallet1",
+		Seed:  testSeed,
+	}, 2))
+
+	// The passphrase is never saved.
+	raw, err := testStorage.Read("wallet0")
+	require.NoError(t, err)
+	w, err := LoadWallet(raw, "wallet0", "password")
+	require.NoError(t, err)
+	require.Empty(t, w.Meta.Passphrase)
+
+	fw0, err := m.DisplayWallet("wallet0", "", 3)
+	require.NoError(t, err)
+	fw1, err := m.DisplayWallet("wallet1", "", 3)
+	require.NoError(t, err)
+	require.NotEqual(t, fw0.Entries[0].Address, fw1.Entries[0].Address)
+
+	// Once locked, more entries can only be generated with the passphrase.
+	require.NoError(t, m.Lock("wallet0"))
+	_, err = m.DisplayWallet("wallet0", "password", 4)
+	require.Equal(t, ErrInvalidPassphrase, err)
+	require.Equal(t, ErrInvalidPassphrase, m.Unlock("wallet0", "password", "wrong"))
+	require.NoError(t, m.Unlock("wallet0", "password", "passphrase"))
+
+	fw, err := m.DisplayWallet("wallet0", "", 4)
+	require.NoError(t, err)
+	require.Len(t, fw.Entries, 4)
+	require.Equal(t, fw0.Entries, fw.Entries[:3])
+
+	// Wallets without a passphrase derive entries as before.
+	require.Equal(t, ErrInvalidPassphrase, m.Unlock("wallet1", "", "passphrase"))
+	sks := cipher.GenerateDeterministicKeyPairs([]byte(testSeed), 1)
+	require.Equal(t, cipher.AddressFromSecKey(sks[0]).String(), fw1.Entries[0].Address)
+}
+
+func TestManager_HDDerivation(t *testing.T) {
+	m, done := newTestManager(t)
+	defer done()
+
+	require.NoError(t, m.NewWallet(&Options{
+		Label:      "wallet0",
+		Seed:       testSeed,
+		Encrypted:  true,
+		Password:   "password",
+		Derivation: HDDerivation,
+	}, 3))
+	require.NoError(t, m.NewWallet(&Options{
+		Label: "wallet1",
+		Seed:  testSeed,
+	}, 3))
+	require.Error(t, m.NewWallet(&Options{
+		Label:      "wallet2",
+		Seed:       testSeed,
+		Derivation: "invalid",
+	}, 3))
+
+	require.NoError(t, m.Refresh())
+
+	fw, err := m.DisplayWallet("wallet0", "password", 5)
+	require.NoError(t, err)
+	require.Equal(t, HDDerivation, fw.Meta.Derivation)
+
+	xpub, path, err := m.ExportXPub("wallet0", "", 0)
+	require.NoError(t, err)
+	require.Equal(t, "m/44'/8000'/0'", path)
+	addrs, err := HDAddresses(xpub, ExternalChain, 0, 5)
+	require.NoError(t, err)
+	for i, e := range fw.Entries {
+		require.Equal(t, addrs[i].String(), e.Address)
+	}
+
+	// Accounts and chains derive different addresses.
+	xpub1, path, err := m.ExportXPub("wallet0", "", 1)
+	require.NoError(t, err)
+	require.Equal(t, "m/44'/8000'/1'", path)
+	require.NotEqual(t, xpub, xpub1)
+	change, err := HDAddresses(xpub, ChangeChain, 0, 1)
+	require.NoError(t, err)
+	require.NotEqual(t, addrs[0], change[0])
+
+	// Wallets of the skycoin chain are unchanged.
+	fw, err = m.DisplayWallet("wallet1", "", 0)
+	require.NoError(t, err)
+	require.Equal(t, SkycoinDerivation, fw.Meta.Derivation)
+	sks := cipher.GenerateDeterministicKeyPairs([]byte(testSeed), 3)
+	for i, e := range fw.Entries {
+		require.Equal(t, cipher.AddressFromSecKey(sks[i]).String(), e.Address)
+	}
+	_, _, err = m.ExportXPub("wallet1", "", 0)
+	require.Equal(t, ErrNotHD, err)
+}
+
+func TestManager_Accounts(t *testing.T) {
+	m, done := newTestManager(t)
+	defer done()
+
+	require.NoError(t, m.NewWallet(&Options{
+		Label:     "wallet0",
+		Seed:      testSeed,
+		Encrypted: true,
+		Password:  "password",
+	}, 3))
+	require.NoError(t, m.NewWallet(&Options{
+		Label:      "wallet1",
+		Seed:       testSeed,
+		Derivation: HDDerivation,
+	}, 3))
+
+	for _, label := range []string{"wallet0", "wallet1"} {
+		stat, err := m.NewAccount(label, "password", "savings", 2)
+		require.NoError(t, err)
+		require.Equal(t, &AccountStat{Index: 1, Name: "savings", EntryCount: 2}, stat)
+
+		_, err = m.NewAccount(label, "", "savings", 2)
+		require.Equal(t, ErrAccountNameExists, err)
+		_, err = m.NewAccount(label, "", "", 2)
+		require.Equal(t, ErrInvalidAccountName, err)
+
+		require.NoError(t, m.RenameAccount(label, "", 1, "spending"))
+		require.Equal(t, ErrAccountNameExists,
+			m.RenameAccount(label, "", 1, DefaultAccountName))
+		require.Equal(t, ErrAccountNotFound,
+			m.RenameAccount(label, "", 2, "other"))
+	}
+
+	// Accounts persist, and are of independent entries.
+	require.NoError(t, m.Refresh())
+	for _, label := range []string{"wallet0", "wallet1"} {
+		accounts, err := m.ListAccounts(label, "password")
+		require.NoError(t, err)
+		require.Equal(t, []AccountStat{
+			{Index: 0, Name: DefaultAccountName, EntryCount: 3},
+			{Index: 1, Name: "spending", EntryCount: 2},
+		}, accounts)
+
+		pw0, err := m.DisplayPaginatedWallet(label, "", 0, 0, 10, -1)
+		require.NoError(t, err)
+		require.Equal(t, DefaultAccountName, pw0.Account.Name)
+		require.Len(t, pw0.Entries, 3)
+
+		pw1, err := m.DisplayPaginatedWallet(label, "", 1, 0, 10, 4)
+		require.NoError(t, err)
+		require.Equal(t, "spending", pw1.Account.Name)
+		require.Equal(t, 4, pw1.TotalCount)
+		require.Len(t, pw1.Entries, 4)
+		for _, e0 := range pw0.Entries {
+			for _, e1 := range pw1.Entries {
+				require.NotEqual(t, e0.Address, e1.Address)
+			}
+		}
+
+		_, err = m.DisplayPaginatedWallet(label, "", 2, 0, 10, -1)
+		require.Equal(t, ErrAccountNotFound, err)
+	}
+
+	// The entries of the second HD account are of it's xpub.
+	pw, err := m.DisplayPaginatedWallet("wallet1", "", 1, 0, 10, -1)
+	require.NoError(t, err)
+	xpub, _, err := m.ExportXPub("wallet1", "", 1)
+	require.NoError(t, err)
+	addrs, err := HDAddresses(xpub, ExternalChain, 0, 4)
+	require.NoError(t, err)
+	for i, e := range pw.Entries {
+		require.Equal(t, addrs[i].String(), e.Address)
+	}
+}
+
+func TestManager_ExportSecrets(t *testing.T) {
+	m, done := newTestManager(t)
+	defer done()
+
+	require.NoError(t, m.NewWallet(&Options{
+		Label:     "wallet0",
+		Seed:      testSeed,
+		Encrypted: true,
+		Password:  "password",
+	}, 2))
+
+	fw, err := m.DisplayWallet("wallet0", "", 2)
+	require.NoError(t, err)
+	raw, err := json.Marshal(fw)
+	require.NoError(t, err)
+	require.NotContains(t, string(raw), testSeed)
+	require.NotContains(t, string(raw), "secret_key")
+
+	// Password is required, even though the wallet is unlocked.
+	_, err = m.ExportSecrets("wallet0", "")
+	require.Equal(t, ErrInvalidPassword, err)
+
+	sfw, err := m.ExportSecrets("wallet0", "password")
+	require.NoError(t, err)
+	require.Equal(t, testSeed, sfw.Meta.Seed)
+	require.Len(t, sfw.Entries, 2)
+	for i, e := range sfw.Entries {
+		require.Equal(t, *fw.Entries[i], e.FloatingEntry)
+		require.NotEmpty(t, e.SecKey)
+	}
+}
+
+func TestManager_NewWatchOnlyWallet(t *testing.T) {
+	m, done := newTestManager(t)
+	defer done()
+
+	var (
+		pk0, _ = cipher.GenerateKeyPair()
+		pk1, _ = cipher.GenerateKeyPair()
+		addr0  = cipher.AddressFromPubKey(pk0)
+		addr1  = cipher.AddressFromPubKey(pk1)
+	)
+
+	entries, err := NewWatchEntries([]string{addr0.String()})
+	require.NoError(t, err)
+
+	require.Error(t, m.NewWatchOnlyWallet(&Options{
+		Label: "wallet0",
+		Seed:  testSeed,
+	}, entries))
+	require.NoError(t, m.NewWatchOnlyWallet(&Options{
+		Label:     "wallet0",
+		WatchOnly: true,
+		Encrypted: true,
+		Password:  "password",
+	}, entries))
+
+	entries, err = NewWatchEntries([]string{pk1.Hex()})
+	require.NoError(t, err)
+	require.NoError(t, m.AddWatchEntries("wallet0", "", entries))
+	require.Equal(t, ErrEntryExists, m.AddWatchEntries("wallet0", "", entries))
+
+	_, err = NewWatchEntries([]string{"invalid"})
+	require.Error(t, err)
+
+	require.NoError(t, m.Refresh())
+
+	fw, err := m.DisplayWallet("wallet0", "password", 0)
+	require.NoError(t, err)
+	require.Equal(t, WatchOnlyKind, fw.Meta.Kind)
+	for _, e := range fw.Entries {
+		require.NotZero(t, e.TS)
+	}
+	require.Equal(t, []*FloatingEntry{
+		{Address: addr0.String(), TS: fw.Entries[0].TS},
+		{Address: addr1.String(), PubKey: pk1.Hex(), TS: fw.Entries[1].TS},
+	}, fw.Entries)
+
+	_, err = m.DisplayWallet("wallet0", "", 3)
+	require.Equal(t, ErrWatchOnly, err)
+
+	_, err = m.ExportSecrets("wallet0", "password")
+	require.Equal(t, ErrWatchOnly, err)
+	_, err = m.SecKey("wallet0", "password", addr0.String())
+	require.Equal(t, ErrWatchOnly, err)
+}
+
+func TestManager_SecKey(t *testing.T) {
+	m, done := newTestManager(t)
+	defer done()
+
+	require.NoError(t, m.NewWallet(&Options{
+		Label:     "wallet0",
+		Seed:      testSeed,
+		Encrypted: true,
+		Password:  "password",
+	}, 2))
+	fw, err := m.DisplayWallet("wallet0", "", 0)
+	require.NoError(t, err)
+	require.NoError(t, m.Lock("wallet0"))
+
+	_, err = m.SecKey("wallet0", "", fw.Entries[1].Address)
+	require.Equal(t, ErrInvalidPassword, err)
+	sk, err := m.SecKey("wallet0", "password", fw.Entries[1].Address)
+	require.NoError(t, err)
+	require.Equal(t, fw.Entries[1].Address, cipher.AddressFromSecKey(sk).String())
+
+	pk, _ := cipher.GenerateKeyPair()
+	_, err = m.SecKey("wallet0", "", cipher.AddressFromPubKey(pk).String())
+	require.Equal(t, ErrEntryNotFound, err)
+	_, err = m.SecKey("wallet0", "", "invalid")
+	require.Error(t, err)
+}
+
+func TestManager_ImportKey(t *testing.T) {
+	m, done := newTestManager(t)
+	defer done()
+
+	require.NoError(t, m.NewWallet(&Options{
+		Label:     "wallet0",
+		Seed:      testSeed,
+		Encrypted: true,
+		Password:  "password",
+	}, 2))
+	require.NoError(t, m.NewWatchOnlyWallet(&Options{
+		Label:     "wallet1",
+		WatchOnly: true,
+	}, nil))
+
+	pk, sk := cipher.GenerateKeyPair()
+	require.Error(t, m.ImportKey("wallet0", "", "invalid"))
+	require.Equal(t, ErrWatchOnly, m.ImportKey("wallet1", "", sk.Hex()))
+	require.NoError(t, m.ImportKey("wallet0", "", sk.Hex()))
+	require.Equal(t, ErrEntryExists, m.ImportKey("wallet0", "", sk.Hex()))
+
+	require.NoError(t, m.Refresh())
+
+	// Imported entries should survive the generation of more entries.
+	fw, err := m.DisplayWallet("wallet0", "password", 5)
+	require.NoError(t, err)
+	require.Len(t, fw.Entries, 5)
+	require.Len(t, fw.ImportedEntries, 1)
+	require.NotZero(t, fw.ImportedEntries[0].TS)
+	require.Equal(t, []*FloatingEntry{{
+		Address:  cipher.AddressFromPubKey(pk).String(),
+		PubKey:   pk.Hex(),
+		Imported: true,
+		TS:       fw.ImportedEntries[0].TS,
+	}}, fw.ImportedEntries)
+
+	sfw, err := m.ExportSecrets("wallet0", "password")
+	require.NoError(t, err)
+	require.Len(t, sfw.ImportedEntries, 1)
+	require.Equal(t, sk.Hex(), sfw.ImportedEntries[0].SecKey)
+}
+
+func TestManager_UpdateEntry(t *testing.T) {
+	m, done := newTestManager(t)
+	defer done()
+
+	require.NoError(t, m.NewWallet(&Options{
+		Label:     "wallet0",
+		Seed:      testSeed,
+		Encrypted: true,
+		Password:  "password",
+	}, 2))
+	_, err := m.NewAccount("wallet0", "", "savings", 1)
+	require.NoError(t, err)
+	pk, sk := cipher.GenerateKeyPair()
+	require.NoError(t, m.ImportKey("wallet0", "", sk.Hex()))
+
+	fw, err := m.DisplayWallet("wallet0", "", 0)
+	require.NoError(t, err)
+	addr0 := fw.Entries[0].Address
+	pw, err := m.DisplayPaginatedWallet("wallet0", "", 1, 0, 10, -1)
+	require.NoError(t, err)
+	addr1 := pw.Entries[0].Address
+	addr2 := cipher.AddressFromPubKey(pk).String()
+
+	label, note, hidden := "kitty shop", "given to alice", true
+	fe, err := m.UpdateEntry("wallet0", "", addr0, &EntryUpdate{Label: &label, Note: &note})
+	require.NoError(t, err)
+	require.Equal(t, label, fe.Label)
+	require.Equal(t, note, fe.Note)
+	require.False(t, fe.Hidden)
+
+	// Fields that are not given are unchanged.
+	fe, err = m.UpdateEntry("wallet0", "", addr0, &EntryUpdate{Hidden: &hidden})
+	require.NoError(t, err)
+	require.Equal(t, label, fe.Label)
+	require.True(t, fe.Hidden)
+
+	fe, err = m.UpdateEntry("wallet0", "", addr1, &EntryUpdate{Label: &label})
+	require.NoError(t, err)
+	require.Equal(t, label, fe.Label)
+	fe, err = m.UpdateEntry("wallet0", "", addr2, &EntryUpdate{Note: &note})
+	require.NoError(t, err)
+	require.True(t, fe.Imported)
+
+	long := strings.Repeat("a", MaxEntryLabelLength+1)
+	_, err = m.UpdateEntry("wallet0", "", addr0, &EntryUpdate{Label: &long})
+	require.IsType(t, ErrValueNotInRange{}, err)
+	_, sk3 := cipher.GenerateKeyPair()
+	_, err = m.UpdateEntry("wallet0", "", cipher.AddressFromSecKey(sk3).String(), &EntryUpdate{})
+	require.Equal(t, ErrEntryNotFound, err)
+	_, err = m.UpdateEntry("wallet0", "", "invalid", &EntryUpdate{})
+	require.Error(t, err)
+
+	// Metadata persists, and survives the generation of more entries.
+	require.NoError(t, m.Refresh())
+	fw, err = m.DisplayWallet("wallet0", "password", 5)
+	require.NoError(t, err)
+	require.Equal(t, label, fw.Entries[0].Label)
+	require.Equal(t, note, fw.Entries[0].Note)
+	require.True(t, fw.Entries[0].Hidden)
+	require.Empty(t, fw.Entries[1].Label)
+	require.Equal(t, note, fw.ImportedEntries[0].Note)
+	pw, err = m.DisplayPaginatedWallet("wallet0", "", 1, 0, 10, 3)
+	require.NoError(t, err)
+	require.Equal(t, label, pw.Entries[0].Label)
+}
+
+func TestManager_MigrateWallet(t *testing.T) {
+	m, done := newTestManager(t)
+	defer done()
+
+	require.NoError(t, saveLegacyWallet(&Options{
+		Label:     "legacy0",
+		Seed:      testSeed,
+		Encrypted: true,
+		Password:  "password",
+	}))
+	require.NoError(t, saveLegacyWallet(&Options{
+		Label: "legacy1",
+		Seed:  testSeed,
+	}))
+	require.NoError(t, m.NewWallet(&Options{Label: "wallet0", Seed: testSeed}, 1))
+
+	// Newer files are listed, and refused when opened.
+	prefix := NewPrefix(Version+1, EmptyNonce())
+	require.NoError(t, testStorage.Write("newer", append(prefix[:], 1, 2, 3)))
+	require.NoError(t, m.Refresh())
+	require.Equal(t, []Stat{
+		{Label: "legacy0", Encrypted: true, Locked: newBool(true), Status: FileOK},
+		{Label: "legacy1", Status: FileOK},
+		{Label: "newer", Locked: newBool(true), Status: FileUnsupported, UnsupportedVersion: Version + 1},
+		{Label: "wallet0", Status: FileOK},
+	}, m.ListWallets())
+	_, err := m.DisplayWallet("newer", "", 0)
+	require.Equal(t, ErrUnsupportedVersion, err)
+	_, err = m.MigrateWallet("newer", "")
+	require.Equal(t, ErrUnsupportedVersion, err)
+
+	_, err = m.MigrateWallet("legacy0", "wrong")
+	require.Error(t, err)
+	for _, label := range []string{"legacy0", "legacy1"} {
+		migrated, err := m.MigrateWallet(label, "password")
+		require.NoError(t, err)
+		require.True(t, migrated)
+		migrated, err = m.MigrateWallet(label, "password")
+		require.NoError(t, err)
+		require.False(t, migrated)
+	}
+	migrated, err := m.MigrateWallet("wallet0", "")
+	require.NoError(t, err)
+	require.False(t, migrated)
+
+	require.NoError(t, m.Refresh())
+	for _, label := range []string{"legacy0", "legacy1"} {
+		fw, err := m.DisplayWallet(label, "password", 0)
+		require.NoError(t, err)
+		require.Equal(t, Version, fw.Meta.Version)
+		require.Len(t, fw.Entries, 2)
+	}
+	require.NoError(t, m.DeleteWallet("newer"))
+}
+
+func TestManager_Backups(t *testing.T) {
+	initTestStorage()
+	m, err := NewManager(&ManagerConfig{
+		Storage:       testStorage,
+		BackupStorage: NewMemoryStorage(),
+		Backups:       2,
+	})
+	require.NoError(t, err)
+
+	count := func(label string) int {
+		fw, err := m.DisplayWallet(label, "", 0)
+		require.NoError(t, err)
+		return len(fw.Entries)
+	}
+
+	// Every save backs up the previous file, and only the last 2 are kept.
+	require.NoError(t, m.NewWallet(&Options{Label: "wallet0", Seed: testSeed}, 1))
+	for _, n := range []int{3, 5, 7} {
+		_, err := m.DisplayWallet("wallet0", "", n)
+		require.NoError(t, err)
+	}
+	backups, err := m.ListBackups("wallet0")
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+	require.True(t, backups[0].TS > backups[1].TS)
+	require.Equal(t, Version, backups[0].Version)
+	require.False(t, backups[0].Encrypted)
+
+	require.NoError(t, m.RestoreBackup("wallet0", backups[1].ID))
+	require.Equal(t, 3, count("wallet0"))
+	require.Equal(t, ErrFileBackupNotFound, m.RestoreBackup("wallet0", "1"))
+	require.Equal(t, ErrFileBackupNotFound, m.RestoreBackup("wallet0", "../wallet0"))
+
+	// The restored file is backed up too, so a restore can be undone.
+	backups, err = m.ListBackups("wallet0")
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+	require.NoError(t, m.RestoreBackup("wallet0", backups[0].ID))
+	require.Equal(t, 7, count("wallet0"))
+
+	// Backups follow renames.
+	require.NoError(t, m.RenameWallet("wallet0", "wallet1"))
+	backups, err = m.ListBackups("wallet1")
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+	old, err := m.ListBackups("wallet0")
+	require.NoError(t, err)
+	require.Empty(t, old)
+	require.Equal(t, 7, count("wallet1"))
+
+	// Deleted wallets can be restored.
+	require.NoError(t, m.DeleteWallet("wallet1"))
+	require.Empty(t, m.ListWallets())
+	backups, err = m.ListBackups("wallet1")
+	require.NoError(t, err)
+	require.NoError(t, m.RestoreBackup("wallet1", backups[0].ID))
+	require.Equal(t, []Stat{{Label: "wallet1", Status: FileOK}}, m.ListWallets())
+	require.Equal(t, 7, count("wallet1"))
+
+	require.NoError(t, m.Refresh())
+	require.Equal(t, []Stat{{Label: "wallet1", Status: FileOK}}, m.ListWallets())
+}
+
+func TestManager_ExportImport(t *testing.T) {
+	m, done := newTestManager(t)
+	defer done()
+
+	require.NoError(t, m.NewWallet(&Options{
+		Label:     "wallet0",
+		Seed:      testSeed,
+		Encrypted: true,
+		Password:  "password",
+	}, 2))
+
+	encode := func(b *Backup) *bytes.Buffer {
+		buf := new(bytes.Buffer)
+		require.NoError(t, json.NewEncoder(buf).Encode(b))
+		return buf
+	}
+
+	enc, err := m.Export("wallet0")
+	require.NoError(t, err)
+	require.True(t, enc.Encrypted)
+	require.NotNil(t, enc.KDF)
+	require.Equal(t, "wallet0", enc.Label)
+
+	_, err = m.ExportPlain("wallet0", "wrong")
+	require.Equal(t, ErrInvalidPassword, err)
+	plain, err := m.ExportPlain("wallet0", "password")
+	require.NoError(t, err)
+	require.False(t, plain.Encrypted)
+	require.Nil(t, plain.KDF)
+
+	_, err = m.Import(encode(enc), &ImportOptions{})
+	require.Equal(t, ErrLabelAlreadyExists, err)
+
+	label, err := m.Import(encode(enc), &ImportOptions{Rename: true})
+	require.NoError(t, err)
+	require.Equal(t, "wallet0_1", label)
+
+	label, err = m.Import(encode(plain), &ImportOptions{Label: "wallet1"})
+	require.NoError(t, err)
+	require.Equal(t, "wallet1", label)
+
+	_, err = m.Import(encode(enc), &ImportOptions{Label: "wallet2", Password: "wrong"})
+	require.Equal(t, ErrInvalidPassword, err)
+
+	newer := *enc
+	newer.FileVersion = Version + 1
+	_, err = m.Import(encode(&newer), &ImportOptions{Label: "wallet2"})
+	require.Equal(t, ErrUnsupportedVersion, err)
+
+	tampered := *enc
+	tampered.Checksum = plain.Checksum
+	_, err = m.Import(encode(&tampered), &ImportOptions{Label: "wallet2"})
+	require.Equal(t, ErrCorruptFile, err)
+
+	// Files that are not listed yet are not overwritten.
+	require.NoError(t, testStorage.Write("external", []byte("external")))
+	_, err = m.Import(encode(enc), &ImportOptions{Label: "external"})
+	require.Equal(t, ErrLabelAlreadyExists, err)
+	raw, err := testStorage.Read("external")
+	require.NoError(t, err)
+	require.Equal(t, []byte("external"), raw)
+	require.NoError(t, testStorage.Delete("external"))
+
+	require.NoError(t, m.Refresh())
+	require.Equal(t, []Stat{
+		{Label: "wallet0", Encrypted: true, Locked: newBool(true), Status: FileOK},
+		{Label: "wallet0_1", Encrypted: true, Locked: newBool(true), Status: FileOK},
+		{Label: "wallet1", Encrypted: false, Status: FileOK},
+	}, m.ListWallets())
+
+	for _, label := range []string{"wallet0_1", "wallet1"} {
+		sfw, err := m.ExportSecrets(label, "password")
+		require.NoError(t, err)
+		require.Equal(t, testSeed, sfw.Meta.Seed)
+		require.Len(t, sfw.Entries, 2)
+	}
+}
+
+// usedAddresses is an AddressChecker of a fixed set of used addresses.
+type usedAddresses map[string]bool
+
+func (u usedAddresses) AddressUsed(address string) (bool, error) {
+	return u[address], nil
+}
+
+func TestManager_RestoreWallet(t *testing.T) {
+	m, done := newTestManager(t)
+	defer done()
+
+	sks := cipher.GenerateDeterministicKeyPairs([]byte(testSeed), 30)
+	address := func(i int) string {
+		return cipher.AddressFromSecKey(sks[i]).String()
+	}
+
+	cases := []struct {
+		Label    string
+		GapLimit int
+		Used     usedAddresses
+		Exp      int
+	}{
+		{"none", 5, usedAddresses{}, 1},
+		{"first", 5, usedAddresses{address(0): true}, 1},
+		{"within gap", 5, usedAddresses{address(2): true, address(7): true}, 8},
+		{"beyond gap", 5, usedAddresses{address(2): true, address(8): true}, 3},
+		{"across batches", 3, usedAddresses{address(2): true, address(5): true, address(8): true}, 9},
+	}
+	for _, c := range cases {
+		count, err := m.RestoreWallet(&Options{
+			Label: c.Label,
+			Seed:  testSeed,
+		}, c.GapLimit, c.Used)
+		require.NoError(t, err, c.Label)
+		require.Equal(t, c.Exp, count, c.Label)
+	}
+
+	_, err := m.RestoreWallet(&Options{Label: "none", Seed: testSeed}, 5, usedAddresses{})
+	require.Equal(t, ErrLabelAlreadyExists, err)
+	_, err = m.RestoreWallet(&Options{Label: "zero", Seed: testSeed}, 0, usedAddresses{})
+	require.IsType(t, ErrValueNotInRange{}, err)
+	_, err = m.RestoreWallet(&Options{Label: "huge", Seed: testSeed}, MaxGapLimit+1, usedAddresses{})
+	require.Equal(t, ErrValueNotInRange{
+		ValName: "gap_limit",
+		HasMin:  true,
+		HasMax:  true,
+		ExpMin:  1,
+		ExpMax:  MaxGapLimit,
+		Got:     MaxGapLimit + 1,
+	}, err)
+
+	require.NoError(t, m.Refresh())
+	fw, err := m.DisplayWallet("within gap", "", 0)
+	require.NoError(t, err)
+	require.Len(t, fw.Entries, 8)
+	require.Equal(t, address(7), fw.Entries[7].Address)
+
+	// More entries continue from the kept entries, not the dropped ones.
+	fw, err = m.DisplayWallet("within gap", "", 10)
+	require.NoError(t, err)
+	require.Len(t, fw.Entries, 10)
+	require.Equal(t, address(8), fw.Entries[8].Address)
+	require.Equal(t, address(9), fw.Entries[9].Address)
+}
+
+func TestManager_Quarantine(t *testing.T) {
+	initTestStorage()
+	require.NoError(t, saveWallet(&Options{
+		Label: "wallet0",
+		Seed:  testSeed,
+	}))
+	require.NoError(t, testStorage.Write("truncated", []byte{1, 2, 3}))
+	prefix := NewPrefix(Version, EmptyNonce())
+	require.NoError(t, testStorage.Write("corrupt", append(prefix[:], 1, 2, 3)))
+
+	// Bad wallet files do not prevent the others from loading.
+	m, err := NewManager(&ManagerConfig{Storage: testStorage})
+	require.NoError(t, err)
+	defer m.Close()
+
+	stats := m.ListWallets()
+	require.Len(t, stats, 3)
+	require.Equal(t, "corrupt", stats[0].Label)
+	require.Equal(t, FileQuarantined, stats[0].Status)
+	require.NotEmpty(t, stats[0].Reason)
+	require.Equal(t, Stat{
+		Label:  "truncated",
+		Locked: newBool(true),
+		Status: FileQuarantined,
+		Reason: ErrFileSize.Error(),
+	}, stats[1])
+	require.Equal(t, Stat{Label: "wallet0", Status: FileOK}, stats[2])
+
+	_, err = m.DisplayWallet("truncated", "", 0)
+	require.Equal(t, ErrWalletQuarantined, err)
+	fw, err := m.DisplayWallet("wallet0", "", 1)
+	require.NoError(t, err)
+	require.Len(t, fw.Entries, 1)
+
+	diagnostics := m.Diagnose()
+	require.Len(t, diagnostics, 3)
+	require.Equal(t, Diagnostic{
+		Label:   "corrupt",
+		Status:  FileQuarantined,
+		Reason:  stats[0].Reason,
+		Size:    PrefixSize + 3,
+		Prefix:  hex.EncodeToString(prefix[:]),
+		Version: Version,
+	}, diagnostics[0])
+	require.Equal(t, Diagnostic{
+		Label:  "truncated",
+		Status: FileQuarantined,
+		Reason: ErrFileSize.Error(),
+		Size:   3,
+	}, diagnostics[1])
+	require.Equal(t, FileOK, diagnostics[2].Status)
+	require.Equal(t, Version, diagnostics[2].Version)
+
+	// Quarantined wallet files can still be deleted.
+	require.NoError(t, m.DeleteWallet("truncated"))
+	require.Len(t, m.ListWallets(), 2)
+}
+
+func TestManager_Labels(t *testing.T) {
+	m, done := newTestManager(t)
+	defer done()
+
+	require.Equal(t, ErrLabelInvalidChars, m.NewWallet(&Options{
+		Label: "../wallet0",
+		Seed:  testSeed,
+	}, 0))
+	require.NoError(t, m.NewWallet(&Options{
+		Label: "Wallet0",
+		Seed:  testSeed,
+	}, 0))
+
+	// Labels are unique ignoring case.
+	require.Equal(t, ErrLabelAlreadyExists, m.NewWallet(&Options{
+		Label: "wallet0",
+		Seed:  testSeed,
+	}, 0))
+	require.Equal(t, ErrLabelReserved, m.RenameWallet("Wallet0", "con"))
+	require.NoError(t, m.RenameWallet("Wallet0", "wallet0"))
+	require.NoError(t, m.RenameWallet("wallet0", "Café"))
+
+	// Wallet files of labels that do not follow the policy are loaded and
+	// flagged, so that they can be renamed.
+	raw, err := testStorage.Read("Café")
+	require.NoError(t, err)
+	require.NoError(t, testStorage.Write("wallet:1", raw))
+	require.NoError(t, m.Refresh())
+	require.Equal(t, []Stat{
+		{Label: "Café", Status: FileOK},
+		{Label: "wallet:1", Status: FileOK, LabelError: ErrLabelInvalidChars.Error()},
+	}, m.ListWallets())
+	require.Equal(t, ErrLabelInvalidChars.Error(), m.Diagnose()[1].LabelError)
+	_, err = m.DisplayWallet("wallet:1", "", 1)
+	require.NoError(t, err)
+	require.Equal(t, ErrLabelInvalidChars, m.RenameWallet("wallet:1", "wallet:2"))
+	require.NoError(t, m.RenameWallet("wallet:1", "wallet1"))
+	require.Equal(t, []Stat{
+		{Label: "Café", Status: FileOK},
+		{Label: "wallet1", Status: FileOK},
+	}, m.ListWallets())
+
+	// Files that can not be loaded are still quarantined.
+	require.NoError(t, testStorage.Write("wallet:3", []byte{}))
+	require.NoError(t, m.Refresh())
+	require.Equal(t, FileQuarantined, m.ListWallets()[2].Status)
+	require.NoError(t, m.DeleteWallet("wallet:3"))
+}
+
+// waitFor fails the test if f does not return within the timeout, or
+// returns an error. As f runs on another goroutine, it must not fail the test
+// itself.
+func waitFor(t *testing.T, timeout time.Duration, f func() error) {
+	done := make(chan error, 1)
+	go func() {
+		done <- f()
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(timeout):
+		t.Fatal("timed out, the manager may be deadlocked")
+	}
+}
+
+func TestManager_WalletLocks(t *testing.T) {
+	m, done := newTestManager(t)
+	defer done()
+
+	for _, label := range []string{"wallet0", "wallet1"} {
+		require.NoError(t, m.NewWallet(&Options{
+			Label:     label,
+			Seed:      testSeed,
+			Encrypted: true,
+			Password:  "password",
+		}, 1))
+	}
+	require.NoError(t, m.Lock("wallet0"))
+
+	// A wallet in use does not block the others.
+	unlock, err := m.lockLabel("wallet0", true)
+	require.NoError(t, err)
+	waitFor(t, 10*time.Second, func() error {
+		if n := len(m.ListWallets()); n != 2 {
+			return fmt.Errorf("listed %d wallets, expected 2", n)
+		}
+		if n := len(m.Diagnose()); n != 2 {
+			return fmt.Errorf("diagnosed %d wallets, expected 2", n)
+		}
+		if _, err := m.DisplayWallet("wallet1", "password", 5); err != nil {
+			return err
+		}
+		if err := m.NewWallet(&Options{
+			Label: "wallet2",
+			Seed:  testSeed,
+		}, 1); err != nil {
+			return err
+		}
+		if err := m.DeleteWallet("wallet2"); err != nil {
+			return err
+		}
+		return m.poll()
+	})
+
+	displayed := make(chan error)
+	go func() {
+		_, err := m.DisplayWallet("wallet0", "password", 5)
+		displayed <- err
+	}()
+	select {
+	case <-displayed:
+		t.Fatal("wallet in use is not waited for")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	require.NoError(t, <-displayed)
+
+	// Operations waiting for a deleted wallet fail.
+	unlock, err = m.lockLabel("wallet1", true)
+	require.NoError(t, err)
+	go func() {
+		_, err := m.DisplayWallet("wallet1", "password", 0)
+		displayed <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	m.mux.Lock()
+	m.remove("wallet1")
+	m.mux.Unlock()
+	unlock()
+	require.Equal(t, ErrWalletNotFound, <-displayed)
+}
+
+// TestManager_Concurrent hammers the manager from many goroutines, and is
+// meant to be run with the race detector.
+func TestManager_Concurrent(t *testing.T) {
+	m, done := newTestManager(t)
+	defer done()
+
+	labels := []string{"wallet0", "wallet1", "wallet2", "wallet3"}
+	for i, label := range labels {
+		require.NoError(t, m.NewWallet(&Options{
+			Label:     label,
+			Seed:      testSeed,
+			Encrypted: i%2 == 0,
+			Password:  "password",
+		}, 1))
+	}
+
+	const iterations = 10
+	workers := []func(i int){
+		func(i int) {
+			m.DisplayWallet(labels[i%len(labels)], "password", i%4)
+		},
+		func(i int) {
+			m.DisplayPaginatedWallet(labels[i%len(labels)], "password", 0, 0, 5, i%4)
+		},
+		func(i int) {
+			m.ListAccounts(labels[i%len(labels)], "password")
+			m.ExportSecrets(labels[i%len(labels)], "password")
+		},
+		func(i int) {
+			m.ListWallets()
+			m.Diagnose()
+			m.Changes(0)
+		},
+		func(i int) {
+			m.Lock(labels[i%len(labels)])
+			m.lockIdle(time.Now().Add(time.Hour))
+		},
+		func(i int) {
+			m.SetEncryption("wallet1", "password", i%2 == 0)
+		},
+		func(i int) {
+			if err := m.RenameWallet("wallet3", "renamed"); err == nil {
+				m.RenameWallet("renamed", "wallet3")
+			}
+		},
+		func(i int) {
+			label := "new" + string('a'+byte(i))
+			if err := m.NewWallet(&Options{Label: label, Seed: testSeed}, 2); err == nil {
+				m.DeleteWallet(label)
+			}
+		},
+		func(i int) {
+			saveWallet(&Options{Label: "external", Seed: testSeed})
+			m.poll()
+			if i%5 == 0 {
+				m.Refresh()
+			}
+		},
+	}
+
+	waitFor(t, time.Minute, func() error {
+		var wg sync.WaitGroup
+		for _, work := range workers {
+			wg.Add(1)
+			go func(work func(int)) {
+				defer wg.Done()
+				for i := 0; i < iterations; i++ {
+					work(i)
+				}
+			}(work)
+		}
+		wg.Wait()
+		return nil
+	})
+
+	stats := m.ListWallets()
+	require.True(t, sort.SliceIsSorted(stats, func(i, j int) bool {
+		return stats[i].Label < stats[j].Label
+	}))
+	for _, stat := range stats {
+		require.Contains(t, m.locks, stat.Label)
+	}
+	require.Len(t, m.locks, len(stats))
+	require.Empty(t, m.reserved)
+}
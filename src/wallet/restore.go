@@ -0,0 +1,85 @@
+package wallet
+
+const (
+	// DefaultGapLimit is the number of consecutive unused addresses after
+	// which address discovery stops.
+	DefaultGapLimit = 20
+
+	// MaxGapLimit is the largest gap limit, as each batch of discovery
+	// derives and checks this many addresses.
+	MaxGapLimit = 1000
+)
+
+// AddressChecker determines whether an address has been used, for example
+// by querying the kitty API for the kitties it owns.
+type AddressChecker interface {
+	AddressUsed(address string) (bool, error)
+}
+
+// Discover generates entries from the seed in batches of gapLimit, until
+// gapLimit consecutive entries are found unused by the checker. The entries
+// up to (and including) the last used entry are kept, with a minimum of one.
+// The gap limit is between 1 and MaxGapLimit.
+func (w *Wallet) Discover(gapLimit int, checker AddressChecker) error {
+	if gapLimit < 1 || gapLimit > MaxGapLimit {
+		return ErrValueNotInRange{
+			ValName: "gap_limit",
+			HasMin:  true,
+			HasMax:  true,
+			ExpMin:  1,
+			ExpMax:  MaxGapLimit,
+			Got:     gapLimit,
+		}
+	}
+	if w.IsWatchOnly() {
+		return ErrWatchOnly
+	}
+
+	var chain Chain
+	generate, err := w.newKeyGenerator(0, &chain)
+	if err != nil {
+		return err
+	}
+
+	var (
+		entries []Entry
+		used    int
+		gap     int
+	)
+	for gap < gapLimit {
+		sks, err := generate(gapLimit)
+		if err != nil {
+			return err
+		}
+		for _, sk := range sks {
+			entry, err := NewEntry(sk)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, *entry)
+
+			ok, err := checker.AddressUsed(entry.Address.String())
+			if err != nil {
+				return err
+			}
+			if ok {
+				used, gap = len(entries), 0
+			} else if gap++; gap == gapLimit {
+				break
+			}
+		}
+	}
+	log.Infof("discovered %d used entries of wallet `%s`", used, w.Meta.Label)
+	if used == 0 {
+		used = 1
+	}
+	w.Entries = entries[:used]
+	if a, err := w.account(0); err == nil {
+		// Discovery always ends with unused entries, which are dropped, so
+		// the chain would continue past the kept entries. It is derived
+		// again from the kept entries when more are needed.
+		a.Chain = Chain{}
+	}
+	w.Meta.Saved = false
+	return nil
+}
@@ -1,8 +1,11 @@
 package wallet
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"math/big"
 	"strconv"
+	"strings"
 
 	"github.com/skycoin/skycoin/src/cipher/go-bip39"
 )
@@ -11,6 +14,37 @@ const (
 	DefaultSeedBitSize = 128
 )
 
+// MnemonicErrorReason determines why a seed is not a valid BIP39 mnemonic.
+type MnemonicErrorReason string
+
+const (
+	MnemonicFormat      MnemonicErrorReason = "format"
+	MnemonicWordCount   MnemonicErrorReason = "word_count"
+	MnemonicUnknownWord MnemonicErrorReason = "unknown_word"
+	MnemonicChecksum    MnemonicErrorReason = "checksum"
+)
+
+// MnemonicError is returned when a seed is not a valid BIP39 mnemonic.
+type MnemonicError struct {
+	Reason MnemonicErrorReason
+	Word   string // The offending word, if any.
+	Index  int    // Index of the offending word, if any.
+	Count  int    // Number of words in the seed.
+}
+
+func (e *MnemonicError) Error() string {
+	switch e.Reason {
+	case MnemonicFormat:
+		return "invalid seed: mnemonic words should be lowercase, and separated by single spaces"
+	case MnemonicWordCount:
+		return fmt.Sprintf("invalid seed: mnemonic has %d words, expected 12, 15, 18, 21 or 24", e.Count)
+	case MnemonicUnknownWord:
+		return fmt.Sprintf("invalid seed: mnemonic word %d '%s' is not in the BIP39 word list", e.Index+1, e.Word)
+	default:
+		return "invalid seed: mnemonic checksum does not match, a word may be mistyped"
+	}
+}
+
 func NewSeed(seedBitSize int) (string, error) {
 	entropy, err := bip39.NewEntropy(seedBitSize)
 	if err != nil {
@@ -39,13 +73,103 @@ func SeedBitSizeFromString(value string) (int, error) {
 	if e != nil {
 		return 0, fmt.Errorf("Malformed integer string: %q", value)
 	}
+	if e := VerifySeedBitSize(requestedBitSize); e != nil {
+		return 0, e
+	}
+	return requestedBitSize, nil
+}
+
+// VerifySeedBitSize checks that a seed bit size is one of our preconfigured
+// bit sizes.
+func VerifySeedBitSize(bitSize int) error {
 	for _, validSize := range ValidSeedBitSizes() {
-		if requestedBitSize == validSize {
-			return requestedBitSize, nil
+		if bitSize == validSize {
+			return nil
 		}
 	}
-	return 0, fmt.Errorf("Unsupported seed bit size: %v (not one of %v)",
-		requestedBitSize,
+	return fmt.Errorf("Unsupported seed bit size: %v (not one of %v)",
+		bitSize,
 		ValidSeedBitSizes(),
 	)
 }
+
+// VerifyMnemonic checks that a seed is a BIP39 mnemonic of the English word
+// list with a valid checksum. A *MnemonicError is returned if it's not.
+func VerifyMnemonic(seed string) error {
+	words := strings.Fields(seed)
+	switch n := len(words); {
+	case strings.Join(words, " ") != seed:
+		return &MnemonicError{Reason: MnemonicFormat, Count: n}
+	case n < 12 || n > 24 || n%3 != 0:
+		return &MnemonicError{Reason: MnemonicWordCount, Count: n}
+	}
+	indices := make([]int, len(words))
+	for i, word := range words {
+		index, ok := bip39.ReverseWordMap[word]
+		if !ok {
+			return &MnemonicError{
+				Reason: MnemonicUnknownWord,
+				Word:   word,
+				Index:  i,
+				Count:  len(words),
+			}
+		}
+		indices[i] = index
+	}
+	if !mnemonicChecksumValid(indices) {
+		return &MnemonicError{Reason: MnemonicChecksum, Count: len(words)}
+	}
+	return nil
+}
+
+// VerifySeed checks a wallet seed. Unless allowRaw is set, the seed needs to
+// be a valid BIP39 mnemonic. If allowRaw is set, seeds that are not BIP39
+// mnemonics are accepted, but seeds that look like one (see
+// looksLikeMnemonic) still need to be valid, as they are likely mistyped.
+func VerifySeed(seed string, allowRaw bool) error {
+	err := VerifyMnemonic(seed)
+	if err == nil || !allowRaw || looksLikeMnemonic(seed) {
+		return err
+	}
+	return nil
+}
+
+// looksLikeMnemonic determines whether a seed is likely meant to be a BIP39
+// mnemonic: it has 12 to 24 words (give or take one that is dropped or
+// repeated), of which most are in the word list (ignoring case). Words may
+// also be mistyped or badly spaced.
+func looksLikeMnemonic(seed string) bool {
+	words := strings.Fields(seed)
+	if len(words) < 11 || len(words) > 25 {
+		return false
+	}
+	known := 0
+	for _, word := range words {
+		if _, ok := bip39.ReverseWordMap[strings.ToLower(word)]; ok {
+			known++
+		}
+	}
+	return known*2 > len(words)
+}
+
+// mnemonicChecksumValid checks the BIP39 checksum of the word list indices of
+// a mnemonic. Each word holds 11 bits, where the last (bits / 33) bits are the
+// leading bits of the SHA256 of the entropy.
+func mnemonicChecksumValid(indices []int) bool {
+	var (
+		bits     = len(indices) * 11
+		csBits   = uint(bits / 33)
+		entBytes = (bits - int(csBits)) / 8
+		b        = new(big.Int)
+	)
+	for _, index := range indices {
+		b.Lsh(b, 11)
+		b.Or(b, big.NewInt(int64(index)))
+	}
+	cs := new(big.Int).And(b, big.NewInt(1<<csBits-1)).Uint64()
+	entropy := new(big.Int).Rsh(b, csBits).Bytes()
+	entropy = append(make([]byte, entBytes-len(entropy)), entropy...)
+
+	hash := sha256.Sum256(entropy)
+	return uint64(hash[0]>>(8-csBits)) == cs
+}
@@ -45,3 +45,61 @@ func TestSeedBitSizeFromString_ValidIntString(t *testing.T) {
 	require.Nil(t, err, "Shouldn't return an error")
 	require.Equal(t, supportedSize, value, "Should return the int we gave it")
 }
+
+func TestVerifySeed(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Seed     string
+		AllowRaw bool
+		Reason   MnemonicErrorReason
+		Word     string
+		Index    int
+	}{
+		{Name: "valid 12 words", Seed: testSeed},
+		{Name: "valid 24 words", Seed: "abandon abandon abandon abandon abandon abandon abandon abandon " +
+			"abandon abandon abandon abandon abandon abandon abandon abandon " +
+			"abandon abandon abandon abandon abandon abandon abandon art"},
+		{Name: "raw seed", Seed: "secure seed", Reason: MnemonicWordCount},
+		{Name: "allowed raw seed", Seed: "secure seed", AllowRaw: true},
+		{Name: "extra space", Seed: testSeed + " ", Reason: MnemonicFormat},
+		{Name: "uppercase word", Seed: "Legal winner thank year wave sausage worth useful legal winner thank yellow",
+			Reason: MnemonicUnknownWord, Word: "Legal", Index: 0},
+		{Name: "mistyped word", Seed: "legal winner thank year wave sausage worth usefull legal winner thank yellow",
+			Reason: MnemonicUnknownWord, Word: "usefull", Index: 7},
+		{Name: "allowed mistyped word", Seed: "legal winner thank year wave sausage worth usefull legal winner thank yellow",
+			AllowRaw: true, Reason: MnemonicUnknownWord, Word: "usefull", Index: 7},
+		{Name: "bad checksum", Seed: "legal winner thank year wave sausage worth useful legal winner thank year",
+			Reason: MnemonicChecksum},
+		{Name: "allowed bad checksum", Seed: "legal winner thank year wave sausage worth useful legal winner thank year",
+			AllowRaw: true, Reason: MnemonicChecksum},
+		{Name: "allowed dropped word", Seed: "legal winner thank year wave sausage worth useful legal winner yellow",
+			AllowRaw: true, Reason: MnemonicWordCount},
+		{Name: "allowed extra word", Seed: testSeed + " legal",
+			AllowRaw: true, Reason: MnemonicWordCount},
+		{Name: "allowed double space", Seed: "legal winner  thank year wave sausage worth useful legal winner thank yellow",
+			AllowRaw: true, Reason: MnemonicFormat},
+		{Name: "allowed mistyped words", Seed: "legal winnr thank year wave sausage worth usefull legal winner thank yellow",
+			AllowRaw: true, Reason: MnemonicUnknownWord, Word: "winnr", Index: 1},
+		{Name: "allowed raw seed of many words", Seed: "my very own kittycash wallet seed of twelve or more words that is not bip39",
+			AllowRaw: true},
+	}
+	for _, c := range cases {
+		err := VerifySeed(c.Seed, c.AllowRaw)
+		if c.Reason == "" {
+			require.NoError(t, err, c.Name)
+			continue
+		}
+		require.IsType(t, &MnemonicError{}, err, c.Name)
+		e := err.(*MnemonicError)
+		require.Equal(t, c.Reason, e.Reason, c.Name)
+		require.Equal(t, c.Word, e.Word, c.Name)
+		require.Equal(t, c.Index, e.Index, c.Name)
+	}
+
+	// Mnemonics generated by NewSeed are always valid.
+	for _, bitSize := range ValidSeedBitSizes() {
+		seed, err := NewSeed(bitSize)
+		require.NoError(t, err)
+		require.NoError(t, VerifyMnemonic(seed))
+	}
+}
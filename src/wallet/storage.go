@@ -0,0 +1,204 @@
+package wallet
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrFileNotFound = errors.New("wallet file is not found")
+)
+
+// Storage stores the raw data of wallet files by label.
+type Storage interface {
+	// List lists the labels of the stored files.
+	List() ([]string, error)
+
+	// Read reads the file of label, or returns ErrFileNotFound.
+	Read(label string) ([]byte, error)
+
+	// Write creates or replaces the file of label. A file is either written
+	// completely or not at all.
+	Write(label string, data []byte) error
+
+	// Delete deletes the file of label, or returns ErrFileNotFound.
+	Delete(label string) error
+
+	// Rename renames the file of label to newLabel, replacing any file of
+	// newLabel. ErrFileNotFound is returned if there is no file of label.
+	Rename(label, newLabel string) error
+}
+
+/*
+	<<< FILE SYSTEM >>>
+*/
+
+// FSStorage stores wallet files in a directory, as '<label>.kcw' files.
+// Labels that are not plain are encoded in the file names (see LabelPath).
+type FSStorage struct {
+	dir string
+}
+
+// NewFSStorage creates a Storage of the files in dir. The directory is
+// created when the first file is written.
+func NewFSStorage(dir string) *FSStorage {
+	return &FSStorage{dir: dir}
+}
+
+// Dir obtains the directory of the files.
+func (s *FSStorage) Dir() string {
+	return s.dir
+}
+
+func (s *FSStorage) List() ([]string, error) {
+	list, err := ioutil.ReadDir(s.dir)
+	switch {
+	case os.IsNotExist(err):
+		return []string{}, nil
+	case err != nil:
+		return nil, err
+	}
+	out := make([]string, 0, len(list))
+	seen := make(map[string]struct{}, len(list))
+	for _, info := range list {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), string(FileExt)) {
+			continue
+		}
+		label := filenameLabel(strings.TrimSuffix(info.Name(), string(FileExt)))
+		if _, ok := seen[label]; ok {
+			continue
+		}
+		seen[label] = struct{}{}
+		out = append(out, label)
+	}
+	return out, nil
+}
+
+// path obtains the path to the file of label. Files of labels that are not
+// plain may have been written with the label as is by older releases, in
+// which case that file is used.
+func (s *FSStorage) path(label string) string {
+	p := LabelPath(s.dir, label)
+	if plainLabel(label) || label == "" || strings.ContainsAny(label, "/\\\x00") {
+		return p
+	}
+	legacy := filepath.Join(s.dir, label+string(FileExt))
+	if _, err := os.Stat(p); os.IsNotExist(err) {
+		if _, err := os.Stat(legacy); err == nil {
+			return legacy
+		}
+	}
+	return p
+}
+
+func (s *FSStorage) Read(label string) ([]byte, error) {
+	data, err := OpenAndReadAll(s.path(label))
+	if os.IsNotExist(err) {
+		return nil, ErrFileNotFound
+	}
+	return data, err
+}
+
+func (s *FSStorage) Write(label string, data []byte) error {
+	if err := os.MkdirAll(s.dir, os.FileMode(0700)); err != nil {
+		return err
+	}
+	return SaveBinary(s.path(label), data)
+}
+
+func (s *FSStorage) Delete(label string) error {
+	err := os.Remove(s.path(label))
+	switch {
+	case os.IsNotExist(err):
+		return ErrFileNotFound
+	case err != nil:
+		return err
+	}
+	return syncDir(s.dir)
+}
+
+func (s *FSStorage) Rename(label, newLabel string) error {
+	err := os.Rename(s.path(label), LabelPath(s.dir, newLabel))
+	switch {
+	case os.IsNotExist(err):
+		return ErrFileNotFound
+	case err != nil:
+		return err
+	}
+	return syncDir(s.dir)
+}
+
+/*
+	<<< MEMORY >>>
+*/
+
+// MemoryStorage stores wallet files in memory, and so never touches disk.
+type MemoryStorage struct {
+	mux   sync.Mutex
+	files map[string][]byte
+}
+
+// NewMemoryStorage creates an empty MemoryStorage.
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{files: make(map[string][]byte)}
+}
+
+func (s *MemoryStorage) List() ([]string, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	out := make([]string, 0, len(s.files))
+	for label := range s.files {
+		out = append(out, label)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func (s *MemoryStorage) Read(label string) ([]byte, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	data, ok := s.files[label]
+	if !ok {
+		return nil, ErrFileNotFound
+	}
+	return append([]byte{}, data...), nil
+}
+
+func (s *MemoryStorage) Write(label string, data []byte) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.files[label] = append([]byte{}, data...)
+	return nil
+}
+
+func (s *MemoryStorage) Delete(label string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, ok := s.files[label]; !ok {
+		return ErrFileNotFound
+	}
+	delete(s.files, label)
+	return nil
+}
+
+func (s *MemoryStorage) Rename(label, newLabel string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	data, ok := s.files[label]
+	if !ok {
+		return ErrFileNotFound
+	}
+	delete(s.files, label)
+	s.files[newLabel] = data
+	return nil
+}
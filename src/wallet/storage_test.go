@@ -0,0 +1,91 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kittycash_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		name string
+		s    Storage
+	}{
+		{"fs", NewFSStorage(dir)},
+		{"memory", NewMemoryStorage()},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			list, err := c.s.List()
+			require.NoError(t, err)
+			require.Empty(t, list)
+
+			_, err = c.s.Read("wallet0")
+			require.Equal(t, ErrFileNotFound, err)
+			require.Equal(t, ErrFileNotFound, c.s.Delete("wallet0"))
+			require.Equal(t, ErrFileNotFound, c.s.Rename("wallet0", "wallet1"))
+
+			require.NoError(t, c.s.Write("wallet0", []byte{1, 2, 3}))
+			require.NoError(t, c.s.Write("wallet0", []byte{4, 5}))
+			raw, err := c.s.Read("wallet0")
+			require.NoError(t, err)
+			require.Equal(t, []byte{4, 5}, raw)
+
+			require.NoError(t, c.s.Write("wallet1", []byte{6}))
+			require.NoError(t, c.s.Rename("wallet0", "wallet1"))
+			raw, err = c.s.Read("wallet1")
+			require.NoError(t, err)
+			require.Equal(t, []byte{4, 5}, raw)
+
+			list, err = c.s.List()
+			require.NoError(t, err)
+			require.Equal(t, []string{"wallet1"}, list)
+
+			require.NoError(t, c.s.Delete("wallet1"))
+			list, err = c.s.List()
+			require.NoError(t, err)
+			require.Empty(t, list)
+		})
+	}
+}
+
+func TestFSStorage_Labels(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kittycash_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s := NewFSStorage(dir)
+	require.NoError(t, s.Write("Café", []byte{1}))
+	require.NoError(t, s.Write("../escape", []byte{2}))
+
+	// Labels that are not plain are encoded, and stay in the directory.
+	infos, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	for _, info := range infos {
+		require.True(t, strings.HasPrefix(info.Name(), encodedLabelPrefix), info.Name())
+	}
+	list, err := s.List()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"Café", "../escape"}, list)
+
+	// Files of older releases, with the label as is, are still used.
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "財布"+string(FileExt)), []byte{3}, 0600))
+	raw, err := s.Read("財布")
+	require.NoError(t, err)
+	require.Equal(t, []byte{3}, raw)
+	require.NoError(t, s.Rename("財布", "Café2"))
+	raw, err = s.Read("Café2")
+	require.NoError(t, err)
+	require.Equal(t, []byte{3}, raw)
+	_, err = s.Read("財布")
+	require.Equal(t, ErrFileNotFound, err)
+}
@@ -2,38 +2,62 @@ package wallet
 
 import (
 	"errors"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"runtime"
 	"time"
 
 	"fmt"
 
 	"github.com/skycoin/skycoin/src/cipher"
-	"github.com/skycoin/skycoin/src/cipher/encoder"
-	"github.com/skycoin/skycoin/src/util/file"
+	"github.com/skycoin/skycoin/src/cipher/go-bip39"
+
+	"github.com/watercompany/kittycash-wallet/src/wallet2"
 )
 
+// The types of the on-disk model, see package wallet2.
 type (
-	// AssetType determines the asset type that the wallet holds.
-	AssetType string
+	AssetType  = wallet2.AssetType
+	Kind       = wallet2.Kind
+	Derivation = wallet2.Derivation
+	Extension  = wallet2.Extension
+
+	// Meta represents the meta that is stored in file.
+	Meta = wallet2.Meta
+
+	// File represents the wallet that is stored in file.
+	File = wallet2.File
 
-	// Extension determines a file's extension.
-	Extension string
+	// Chain represents where the key chain of an account stopped.
+	Chain = wallet2.Chain
 )
 
 var (
 	ErrInvalidPassword    = errors.New("invalid password")
 	ErrInvalidCredentials = errors.New("failed to read wallet file, maybe due to incorrect credentials")
+	ErrUnsupportedVersion = wallet2.ErrUnsupportedVersion
+	ErrWatchOnly          = errors.New("wallet is watch-only and holds no secret keys")
+	ErrEntryExists        = errors.New("address already exists in wallet")
+	ErrInvalidPassphrase  = errors.New("seed passphrase does not match the wallet's entries")
+	ErrNotHD              = errors.New("wallet does not use hierarchical deterministic derivation")
+	ErrNotWatchOnly       = errors.New("wallet is not watch-only")
+	ErrMissingSeed        = errors.New("invalid seed: seed is missing")
+	ErrWatchOnlySeed      = errors.New("watch-only wallet can not have a seed")
+	ErrInvalidDerivation  = errors.New("invalid derivation")
+	ErrMissingPassword    = errors.New("invalid password: encrypted wallet needs a password")
 )
 
 const (
-	// Version determines the wallet file's version.
-	Version uint64 = 0
-
-	// KittyAsset represents the "kittycash" asset type.
-	KittyAsset AssetType = "kittycash"
-
-	// FileExt is the kittycash file extension.
-	FileExt Extension = ".kcw"
+	// Version is the latest wallet file version, see wallet2.Version.
+	Version = wallet2.Version
+
+	KittyAsset        = wallet2.KittyAsset
+	DeterministicKind = wallet2.DeterministicKind
+	WatchOnlyKind     = wallet2.WatchOnlyKind
+	SkycoinDerivation = wallet2.SkycoinDerivation
+	HDDerivation      = wallet2.HDDerivation
+	FileExt           = wallet2.FileExt
 )
 
 /*
@@ -47,61 +71,98 @@ type FloatingMeta struct {
 	Encrypted bool   `json:"encrypted"`
 	Password  string `json:"-"`
 	Saved     bool   `json:"-"`
+
+	// Passphrase is the BIP39 passphrase of the seed. It is never saved, and
+	// needs to be supplied again whenever the wallet is loaded.
+	Passphrase string `json:"-"`
 	Meta
 }
 
-// Meta represents the meta that is stored in file.
-type Meta struct {
-	AssetType AssetType `json:"type"`
-	Seed      string    `json:"seed"`
-	TS        int64     `json:"timestamp"`
+// ToPublic obtains the meta that can be displayed without revealing secrets.
+func (m *FloatingMeta) ToPublic() PublicFloatingMeta {
+	return PublicFloatingMeta{
+		Version:    m.Version,
+		Label:      m.Label,
+		Encrypted:  m.Encrypted,
+		AssetType:  m.AssetType,
+		Kind:       m.Kind,
+		Derivation: m.Derivation,
+		TS:         m.TS,
+	}
+}
+
+// PublicFloatingMeta represents the wallet meta that is displayed in api.
+// It contains no secrets.
+type PublicFloatingMeta struct {
+	Version    uint64     `json:"version"`
+	Label      string     `json:"label"`
+	Encrypted  bool       `json:"encrypted"`
+	AssetType  AssetType  `json:"type"`
+	Kind       Kind       `json:"kind"`
+	Derivation Derivation `json:"derivation,omitempty"`
+	TS         int64      `json:"timestamp"`
+}
+
+// SecretFloatingMeta represents the wallet meta, including the seed, that is
+// displayed in api when secrets are exported.
+type SecretFloatingMeta struct {
+	PublicFloatingMeta
+	Seed string `json:"seed"`
 }
 
 // FloatingWallet represents the wallet that is not saved, but displayed in api.
+// It contains no secrets.
 type FloatingWallet struct {
-	Meta       FloatingMeta     `json:"meta"`
-	EntryCount int              `json:"entry_count"`
-	Entries    []*FloatingEntry `json:"entries"`
+	Meta            PublicFloatingMeta `json:"meta"`
+	EntryCount      int                `json:"entry_count"`
+	Entries         []*FloatingEntry   `json:"entries"`
+	ImportedEntries []*FloatingEntry   `json:"imported_entries"`
+	Accounts        []AccountStat      `json:"accounts"`
+}
+
+// SecretFloatingWallet represents the wallet, including it's seed and secret
+// keys, that is displayed in api when secrets are exported.
+type SecretFloatingWallet struct {
+	Meta            SecretFloatingMeta     `json:"meta"`
+	EntryCount      int                    `json:"entry_count"`
+	Entries         []*SecretFloatingEntry `json:"entries"`
+	ImportedEntries []*SecretFloatingEntry `json:"imported_entries"`
+
+	// Accounts are the accounts other than the first, whose entries are
+	// Entries.
+	Accounts []*SecretFloatingAccount `json:"accounts"`
 }
 
 type PaginatedFloatingWallet struct {
-	Meta       FloatingMeta     `json:"meta"`
-	StartIndex int              `json:"start_index"`
-	PageSize   int              `json:"page_size"`
-	LastPage   bool             `json:"last_page"`
-	TotalCount int              `json:"total_count"`
-	Entries    []*FloatingEntry `json:"entries"`
+	Meta            PublicFloatingMeta `json:"meta"`
+	Account         AccountStat        `json:"account"`
+	StartIndex      int                `json:"start_index"`
+	PageSize        int                `json:"page_size"`
+	LastPage        bool               `json:"last_page"`
+	TotalCount      int                `json:"total_count"`
+	Entries         []*FloatingEntry   `json:"entries"`
+	ImportedEntries []*FloatingEntry   `json:"imported_entries"`
 }
 
 // Wallet represents the wallet that is stored in memory.
 type Wallet struct {
 	Meta    FloatingMeta
 	Entries []Entry
-}
 
-// File represents the wallet that is stored in file.
-type File struct {
-	Meta    Meta
-	Entries []Entry
-}
+	// Imported are entries of secret keys that are not generated from the
+	// seed. They are kept apart from Entries, which EnsureEntries regenerates.
+	Imported []Entry
 
-// FileFromRaw extracts File from raw data.
-func FileFromRaw(b []byte) (*File, error) {
-	var (
-		out = new(File)
-		err error
-	)
-	defer func() {
-		if r := recover(); r != nil {
-			err = fmt.Errorf("failed to read wallet file: %v", r)
-		}
-	}()
-	err = encoder.DeserializeRaw(b, out)
-	return out, err
+	// Accounts are the named accounts of the wallet. The first account's
+	// entries are Entries.
+	Accounts []Account
+
+	lastUsed time.Time
 }
 
-func (w File) Serialize() []byte {
-	return encoder.Serialize(w)
+// FileFromRaw extracts File of the latest Version from raw data.
+func FileFromRaw(b []byte) (*File, error) {
+	return wallet2.Decode(Version, b)
 }
 
 /*
@@ -112,20 +173,43 @@ func (w File) Serialize() []byte {
 type Options struct {
 	Label     string `json:"string"`
 	Seed      string `json:"seed"`
+	WatchOnly bool   `json:"watch_only"`
 	Encrypted bool   `json:"encrypted"`
 	Password  string `json:"password,omitempty"`
+
+	// AllowRawSeed accepts seeds that are not BIP39 mnemonics.
+	AllowRawSeed bool `json:"allow_raw_seed"`
+
+	// Passphrase is an optional BIP39 passphrase of the seed. Each passphrase
+	// derives a different wallet. It is never saved.
+	Passphrase string `json:"-"`
+
+	// Derivation of the entries from the seed. SkycoinDerivation is used if
+	// empty.
+	Derivation Derivation `json:"derivation"`
 }
 
 // Verify checks the validity of Options.
 func (o *Options) Verify() error {
-	if o.Label == "" {
-		return errors.New("invalid label")
+	if err := VerifyLabel(o.Label); err != nil {
+		return err
 	}
-	if o.Seed == "" {
-		return errors.New("invalid seed")
+	if o.WatchOnly {
+		if o.Seed != "" || o.Passphrase != "" || o.Derivation != "" {
+			return ErrWatchOnlySeed
+		}
+	} else if o.Seed == "" {
+		return ErrMissingSeed
+	} else if err := VerifySeed(o.Seed, o.AllowRawSeed); err != nil {
+		return err
+	}
+	switch o.Derivation {
+	case "", SkycoinDerivation, HDDerivation:
+	default:
+		return ErrInvalidDerivation
 	}
 	if o.Encrypted && o.Password == "" {
-		return errors.New("invalid password")
+		return ErrMissingPassword
 	}
 	return nil
 }
@@ -136,23 +220,37 @@ func NewWallet(options *Options) (*Wallet, error) {
 		return nil, err
 	}
 
+	kind, derivation := DeterministicKind, options.Derivation
+	if options.WatchOnly {
+		kind = WatchOnlyKind
+	} else if derivation == "" {
+		derivation = SkycoinDerivation
+	}
+
 	return &Wallet{
 		Meta: FloatingMeta{
-			Version:   Version,
-			Label:     options.Label,
-			Encrypted: options.Encrypted,
-			Password:  options.Password,
+			Version:    Version,
+			Label:      options.Label,
+			Encrypted:  options.Encrypted,
+			Password:   options.Password,
+			Passphrase: options.Passphrase,
 			Meta: Meta{
-				AssetType: KittyAsset,
-				Seed:      options.Seed,
-				TS:        time.Now().UnixNano(),
+				AssetType:  KittyAsset,
+				Kind:       kind,
+				Derivation: derivation,
+				Seed:       options.Seed,
+				TS:         time.Now().UnixNano(),
 			},
 		},
-		Entries: []Entry{},
+		Entries:  []Entry{},
+		Accounts: defaultAccounts(),
+		lastUsed: time.Now(),
 	}, nil
 }
 
 // LoadWallet loads a wallet from a wallet file.
+// ErrInvalidPassword is returned if the password is wrong, and ErrCorruptFile
+// if the file fails integrity checks.
 func LoadWallet(raw []byte, label, password string) (*Wallet, error) {
 	prefix, data, err := ExtractPrefix(raw)
 	if err != nil {
@@ -160,25 +258,15 @@ func LoadWallet(raw []byte, label, password string) (*Wallet, error) {
 	}
 	encrypted := prefix.Encrypted()
 
-	fmt.Printf("WALLET: v(%v) e(%v) n(%v) \n",
-		prefix.Version(), prefix.Encrypted(), prefix.Nonce())
-
-	if encrypted {
-		pHash := cipher.SumSHA256([]byte(password))
-		data, err = cipher.Chacha20Decrypt(data, pHash[:], prefix.Nonce())
-		if err != nil {
-			log.Errorf("failed to decrypt wallet file, error: %v", err)
-			return nil, ErrInvalidCredentials
-		}
-	} else {
-		password = ""
-	}
+	log.Debugf("loading wallet `%s` of version %v (encrypted: %v)",
+		label, prefix.Version(), encrypted)
 
-	wallet, err := FileFromRaw(data)
+	wallet, err := decodeFile(prefix, data, password)
 	if err != nil {
 		return nil, err
-	} else if wallet == nil {
-		return nil, ErrInvalidCredentials
+	}
+	if !encrypted {
+		password = ""
 	}
 
 	return &Wallet{
@@ -187,80 +275,271 @@ func LoadWallet(raw []byte, label, password string) (*Wallet, error) {
 			Label:     label,
 			Encrypted: encrypted,
 			Password:  password,
-			Meta:      wallet.Meta,
+			// Older versions are not marked as saved, so that they are
+			// upgraded to the current version on the next save.
+			Saved: prefix.Version() == Version,
+			Meta:  wallet.Meta,
 		},
-		Entries: wallet.Entries,
+		Entries:  entriesFromFile(wallet.Entries),
+		Imported: entriesFromFile(wallet.Imported),
+		Accounts: accountsFromFile(wallet.Accounts),
 	}, nil
 }
 
 // Save saves the wallet back to file.
-func (w *Wallet) Save(rootDir string) error {
-	version := w.Meta.Version
-
-	nonce := EmptyNonce()
-	if w.Meta.Encrypted {
-		nonce = RandNonce()
+// The file is always written with the latest Version.
+func (w *Wallet) Save(s Storage) error {
+	raw, err := encodeFile(w.ToFile(), w.Meta.Encrypted, w.Meta.Password)
+	if err != nil {
+		return err
+	}
+	if err := s.Write(w.Meta.Label, raw); err != nil {
+		return err
 	}
 
-	prefix := NewPrefix(version, nonce)
+	w.Meta.Version = Version
+	w.Meta.Saved = true
+	return nil
+}
 
-	data := w.ToFile().Serialize()
-	if w.Meta.Encrypted {
-		var err error
-		pHash := cipher.SumSHA256([]byte(w.Meta.Password))
-		data, err = cipher.Chacha20Encrypt(data, pHash[:], nonce)
-		if err != nil {
+// EnsureEntries ensures that the first account has at least n entries.
+func (w *Wallet) EnsureEntries(n int) error {
+	return w.EnsureAccountEntries(0, n)
+}
+
+// SetPassphrase sets the BIP39 passphrase of the seed, which is needed to
+// generate more entries. ErrInvalidPassphrase is returned if the passphrase
+// does not derive the wallet's existing entries.
+func (w *Wallet) SetPassphrase(passphrase string) error {
+	if w.IsWatchOnly() {
+		return ErrWatchOnly
+	}
+	prev := w.Meta.Passphrase
+	w.Meta.Passphrase = passphrase
+	if w.Count() > 0 {
+		if err := w.verifyFirstEntry(0, w.Entries[0]); err != nil {
+			w.Meta.Passphrase = prev
 			return err
 		}
 	}
+	return nil
+}
 
-	err := SaveBinary(
-		LabelPath(rootDir, w.Meta.Label),
-		append(prefix[:], data...),
-	)
+// verifyFirstEntry checks that the first entry of an account is generated
+// from the seed and passphrase, and returns ErrInvalidPassphrase otherwise.
+func (w *Wallet) verifyFirstEntry(account uint32, first Entry) error {
+	generate, err := w.newKeyGenerator(account, &Chain{})
 	if err != nil {
 		return err
 	}
+	sks, err := generate(1)
+	if err != nil {
+		return err
+	}
+	if cipher.AddressFromSecKey(sks[0]) != first.Address {
+		return ErrInvalidPassphrase
+	}
+	return nil
+}
 
-	w.Meta.Saved = true
+// ExtendedPublicKey obtains the BIP32 extended public key (xpub) of an
+// account of a wallet with HDDerivation, along with the account's path.
+func (w *Wallet) ExtendedPublicKey(account uint32) (string, string, error) {
+	if w.Meta.Derivation != HDDerivation {
+		return "", "", ErrNotHD
+	}
+	k, err := NewHDAccountKey(w.derivationSeed(), account)
+	if err != nil {
+		return "", "", err
+	}
+	return k.Neuter().String(), FormatHDPath(HDAccountPath(account)), nil
+}
+
+// keyGenerator generates the secret keys of a wallet's entries in order.
+// Each call continues where the previous call stopped.
+type keyGenerator func(n int) ([]cipher.SecKey, error)
+
+// newKeyGenerator creates a keyGenerator of an account, of the wallet's
+// Derivation. Entries of HDDerivation are of the external chain. The
+// generator continues where chain stopped, and updates it as keys are
+// generated, so the zero Chain starts with the first key.
+func (w *Wallet) newKeyGenerator(account uint32, chain *Chain) (keyGenerator, error) {
+	switch w.Meta.Derivation {
+	case SkycoinDerivation:
+		seed := chain.Seed[:]
+		if chain.Count == 0 {
+			seed = skycoinAccountSeed(w.derivationSeed(), account)
+		}
+		return func(n int) ([]cipher.SecKey, error) {
+			if n <= 0 {
+				return nil, nil
+			}
+			var sks []cipher.SecKey
+			seed, sks = cipher.GenerateDeterministicKeyPairsSeed(seed, n)
+			copy(chain.Seed[:], seed)
+			chain.Count += uint64(n)
+			return sks, nil
+		}, nil
+
+	case HDDerivation:
+		accountKey, err := NewHDAccountKey(w.derivationSeed(), account)
+		if err != nil {
+			return nil, err
+		}
+		return func(n int) ([]cipher.SecKey, error) {
+			keys, err := HDChainKeys(accountKey, ExternalChain, chain.Next, n)
+			if err != nil {
+				return nil, err
+			}
+			sks := make([]cipher.SecKey, len(keys))
+			for i, k := range keys {
+				sks[i] = k.SecKey
+				chain.Next = k.ChildNumber + 1
+			}
+			chain.Count += uint64(len(keys))
+			return sks, nil
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("wallet has unknown derivation '%s'", w.Meta.Derivation)
+	}
+}
+
+// derivationSeed obtains the seed that entries are generated from.
+// For SkycoinDerivation without a passphrase, this is the seed itself, as
+// with wallets created before passphrases were supported. Otherwise, it is
+// the BIP39 seed of the mnemonic and passphrase.
+func (w *Wallet) derivationSeed() []byte {
+	if w.Meta.Derivation == SkycoinDerivation && w.Meta.Passphrase == "" {
+		return []byte(w.Meta.Seed)
+	}
+	return bip39.NewSeed(w.Meta.Seed, w.Meta.Passphrase)
+}
+
+// AddWatchEntries appends entries to a watch-only wallet.
+func (w *Wallet) AddWatchEntries(entries []Entry) error {
+	if !w.IsWatchOnly() {
+		return ErrNotWatchOnly
+	}
+	exists := make(map[cipher.Address]struct{}, w.Count()+len(entries))
+	for _, e := range w.Entries {
+		exists[e.Address] = struct{}{}
+	}
+	for _, e := range entries {
+		if _, ok := exists[e.Address]; ok {
+			return ErrEntryExists
+		}
+		exists[e.Address] = struct{}{}
+	}
+	w.Entries = append(w.Entries, entries...)
+	w.Meta.Saved = false
 	return nil
 }
 
-func (w *Wallet) EnsureEntries(n int) error {
-	switch {
-	case n < 0:
-		return errors.New("can not have negative number of entries")
-	case n <= w.Count():
-		return nil
+// ImportKey adds an entry of a secret key that is not generated from the seed.
+func (w *Wallet) ImportKey(sk cipher.SecKey) error {
+	if w.IsWatchOnly() {
+		return ErrWatchOnly
 	}
-	sks := cipher.GenerateDeterministicKeyPairs([]byte(w.Meta.Seed), n)
-	w.Entries = make([]Entry, n)
-	for i := 0; i < n; i++ {
-		entry, _ := NewEntry(sks[i])
-		w.Entries[i] = *entry
+	entry, err := NewEntry(sk)
+	if err != nil {
+		return err
 	}
+	for _, entries := range w.allEntries() {
+		for _, e := range entries {
+			if e.Address == entry.Address {
+				return ErrEntryExists
+			}
+		}
+	}
+	w.Imported = append(w.Imported, *entry)
+	w.Meta.Saved = false
+	return nil
+}
 
+// UpdateEntry updates the metadata of the entry of address, of any account
+// or imported. The updated entry is returned.
+func (w *Wallet) UpdateEntry(address cipher.Address, update *EntryUpdate) (*Entry, error) {
+	if err := update.Verify(); err != nil {
+		return nil, err
+	}
+	entry := w.findEntry(address)
+	if entry == nil {
+		return nil, ErrEntryNotFound
+	}
+	update.Apply(entry)
 	w.Meta.Saved = false
+	return entry, nil
+}
+
+// findEntry obtains the entry of address, or nil if there is none.
+func (w *Wallet) findEntry(address cipher.Address) *Entry {
+	for _, entries := range w.allEntries() {
+		for i := range entries {
+			if entries[i].Address == address {
+				return &entries[i]
+			}
+		}
+	}
 	return nil
 }
 
+// IsWatchOnly returns true if the wallet holds no seed and secret keys.
+func (w *Wallet) IsWatchOnly() bool {
+	return w.Meta.Kind == WatchOnlyKind
+}
+
+// Erase removes the secrets of the wallet from memory.
+// Secret keys and key chains are zeroed. The seed, password and passphrase
+// are strings, which can not be zeroed, so they are only released, and their
+// contents remain in memory until it is reused.
+func (w *Wallet) Erase() {
+	for _, entries := range w.allEntries() {
+		for i := range entries {
+			entries[i].SecKey = cipher.SecKey{}
+		}
+	}
+	for i := range w.Accounts {
+		w.Accounts[i].Chain = Chain{}
+	}
+	w.Entries = nil
+	w.Imported = nil
+	w.Accounts = nil
+	w.Meta.Seed = ""
+	w.Meta.Password = ""
+	w.Meta.Passphrase = ""
+}
+
 func (w *Wallet) Count() int {
 	return len(w.Entries)
 }
 
+// allEntries obtains the entries of all accounts, and the imported entries.
+func (w *Wallet) allEntries() [][]Entry {
+	out := [][]Entry{w.Entries, w.Imported}
+	for _, a := range w.Accounts {
+		out = append(out, a.Entries)
+	}
+	return out
+}
+
 func (w *Wallet) ToFile() *File {
 	return &File{
-		Meta:    w.Meta.Meta,
-		Entries: w.Entries,
+		Meta:     w.Meta.Meta,
+		Entries:  entriesToFile(w.Entries),
+		Imported: entriesToFile(w.Imported),
+		Accounts: accountsToFile(w.Accounts),
 	}
 }
 
 func (w *Wallet) ToFloating() *FloatingWallet {
 	count := len(w.Entries)
 	fw := &FloatingWallet{
-		Meta:       w.Meta,
-		EntryCount: count,
-		Entries:    make([]*FloatingEntry, count),
+		Meta:            w.Meta.ToPublic(),
+		EntryCount:      count,
+		Entries:         make([]*FloatingEntry, count),
+		ImportedEntries: w.floatingImported(),
+		Accounts:        w.ListAccounts(),
 	}
 	for i, entry := range w.Entries {
 		fw.Entries[i] = entry.ToFloating()
@@ -268,8 +547,59 @@ func (w *Wallet) ToFloating() *FloatingWallet {
 	return fw
 }
 
-func (w *Wallet) ToPaginatedFloating(startIndex, pageSize int) (*PaginatedFloatingWallet, error) {
-	totalCount := len(w.Entries)
+func (w *Wallet) ToSecretFloating() *SecretFloatingWallet {
+	count := len(w.Entries)
+	fw := &SecretFloatingWallet{
+		Meta: SecretFloatingMeta{
+			PublicFloatingMeta: w.Meta.ToPublic(),
+			Seed:               w.Meta.Seed,
+		},
+		EntryCount:      count,
+		Entries:         make([]*SecretFloatingEntry, count),
+		ImportedEntries: make([]*SecretFloatingEntry, len(w.Imported)),
+		Accounts:        make([]*SecretFloatingAccount, 0, len(w.Accounts)),
+	}
+	for i, entry := range w.Entries {
+		fw.Entries[i] = entry.ToSecretFloating()
+	}
+	for i, entry := range w.Imported {
+		fw.ImportedEntries[i] = entry.ToSecretFloating()
+		fw.ImportedEntries[i].Imported = true
+	}
+	for i := range w.Accounts {
+		a := &w.Accounts[i]
+		if a.Index == 0 {
+			continue
+		}
+		sa := &SecretFloatingAccount{
+			AccountStat: *w.accountStat(a),
+			Entries:     make([]*SecretFloatingEntry, len(a.Entries)),
+		}
+		for j, entry := range a.Entries {
+			sa.Entries[j] = entry.ToSecretFloating()
+		}
+		fw.Accounts = append(fw.Accounts, sa)
+	}
+	return fw
+}
+
+func (w *Wallet) floatingImported() []*FloatingEntry {
+	out := make([]*FloatingEntry, len(w.Imported))
+	for i, entry := range w.Imported {
+		out[i] = entry.ToFloating()
+		out[i].Imported = true
+	}
+	return out
+}
+
+// ToPaginatedFloating displays a page of the entries of an account.
+func (w *Wallet) ToPaginatedFloating(account uint32, startIndex, pageSize int) (*PaginatedFloatingWallet, error) {
+	a, err := w.account(account)
+	if err != nil {
+		return nil, err
+	}
+	entries := w.accountEntries(a)
+	totalCount := len(entries)
 
 	log.Infof("start(%d) page(%d) total(%d)",
 		startIndex, pageSize, totalCount)
@@ -282,15 +612,17 @@ func (w *Wallet) ToPaginatedFloating(startIndex, pageSize int) (*PaginatedFloati
 	log.Info(p)
 
 	out := PaginatedFloatingWallet{
-		Meta:       w.Meta,
-		StartIndex: startIndex,
-		PageSize:   p.NewPageSize,
-		LastPage:   p.LastPage,
-		TotalCount: totalCount,
-		Entries:    make([]*FloatingEntry, p.NewPageSize),
+		Meta:            w.Meta.ToPublic(),
+		Account:         *w.accountStat(a),
+		StartIndex:      startIndex,
+		PageSize:        p.NewPageSize,
+		LastPage:        p.LastPage,
+		TotalCount:      totalCount,
+		Entries:         make([]*FloatingEntry, p.NewPageSize),
+		ImportedEntries: w.floatingImported(),
 	}
 	for i, j := 0, startIndex; i < p.NewPageSize; i, j = i+1, j+1 {
-		out.Entries[i] = w.Entries[j].ToFloating()
+		out.Entries[i] = entries[j].ToFloating()
 	}
 	return &out, nil
 }
@@ -391,6 +723,51 @@ func CheckPaginated(startIndex, pageSize, totalCount int) (*CheckPaginatedOut, e
 	<<< HELPERS >>>
 */
 
+// SaveBinary atomically replaces the file at fn with data.
+// The data is written to a temporary file in the same directory, synced, and
+// then renamed over fn, so an interrupted write never leaves a partial file.
 func SaveBinary(fn string, data []byte) error {
-	return file.SaveBinary(fn, data, os.FileMode(0600))
+	dir, name := filepath.Split(fn)
+	f, err := ioutil.TempFile(dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := f.Name()
+	defer os.Remove(tmpName)
+
+	if err := f.Chmod(os.FileMode(0600)); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, fn); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// syncDir flushes directory entries (such as a rename) to disk.
+func syncDir(dir string) error {
+	if dir == "" {
+		dir = "."
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil && runtime.GOOS != "windows" {
+		return err
+	}
+	return nil
 }
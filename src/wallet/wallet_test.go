@@ -1,24 +1,26 @@
 package wallet
 
 import (
-	"io/ioutil"
-	"os"
 	"testing"
 
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
 	"github.com/stretchr/testify/require"
 )
 
-var testRootDir string
+// testSeed is a valid BIP39 mnemonic.
+const testSeed = "legal winner thank year wave sausage worth useful legal winner thank yellow"
 
-func initTempDir(t *testing.T) func() {
-	dir, err := ioutil.TempDir("", "kittycash_test")
-	require.Empty(t, err)
+var testStorage Storage
 
-	testRootDir = dir
+func init() {
+	scryptN = 1 << 10
+}
 
-	return func() {
-		os.RemoveAll(dir)
-	}
+// initTestStorage replaces the storage of the wallet files of a test with an
+// empty MemoryStorage, so that tests never touch disk.
+func initTestStorage() {
+	testStorage = NewMemoryStorage()
 }
 
 func saveWallet(options *Options) error {
@@ -26,16 +28,11 @@ func saveWallet(options *Options) error {
 	if err != nil {
 		return err
 	}
-	return fWallet.Save(testRootDir)
+	return fWallet.Save(testStorage)
 }
 
 func loadWallet(label, pw string) (*Wallet, error) {
-	f, err := os.Open(LabelPath(testRootDir, label))
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-	raw, err := ioutil.ReadAll(f)
+	raw, err := testStorage.Read(label)
 	if err != nil {
 		return nil, err
 	}
@@ -43,19 +40,18 @@ func loadWallet(label, pw string) (*Wallet, error) {
 }
 
 func TestFloatingWallet_Save(t *testing.T) {
-	rmTemp := initTempDir(t)
-	defer rmTemp()
+	initTestStorage()
 
 	cases0 := []*Options{
 		{
 			Label:     "wallet0",
-			Seed:      "secure seed",
+			Seed:      testSeed,
 			Encrypted: true,
 			Password:  "password",
 		},
 		{
 			Label:     "wallet1",
-			Seed:      "secure seed",
+			Seed:      testSeed,
 			Encrypted: false,
 			Password:  "",
 		},
@@ -90,8 +86,236 @@ func TestFloatingWallet_Save(t *testing.T) {
 			if _, err := loadWallet(c.Correct.Label, c.FalsePass); c.ShouldPass {
 				require.NoError(t, err)
 			} else {
-				require.Error(t, err)
+				require.Equal(t, ErrInvalidPassword, err)
 			}
 		}
 	})
 }
+
+// legacyFile is the layout of the body of version 0 wallet files.
+type legacyFile struct {
+	Meta struct {
+		AssetType AssetType
+		Seed      string
+		TS        int64
+	}
+	Entries []legacyEntry
+}
+
+type legacyEntry struct {
+	Address cipher.Address
+	PubKey  cipher.PubKey
+	SecKey  cipher.SecKey
+}
+
+// saveLegacyWallet saves a wallet in the version 0 file format.
+func saveLegacyWallet(options *Options) error {
+	fWallet, err := NewWallet(options)
+	if err != nil {
+		return err
+	}
+	if err := fWallet.EnsureEntries(2); err != nil {
+		return err
+	}
+	var file legacyFile
+	file.Meta.AssetType = fWallet.Meta.AssetType
+	file.Meta.Seed = fWallet.Meta.Seed
+	file.Meta.TS = fWallet.Meta.TS
+	for _, e := range fWallet.Entries {
+		file.Entries = append(file.Entries, legacyEntry{
+			Address: e.Address,
+			PubKey:  e.PubKey,
+			SecKey:  e.SecKey,
+		})
+	}
+	nonce := EmptyNonce()
+	data := encoder.Serialize(file)
+	if options.Encrypted {
+		nonce = RandNonce()
+		if data, err = cipher.Chacha20Encrypt(data, legacyKey(options.Password), nonce); err != nil {
+			return err
+		}
+	}
+	prefix := NewPrefix(0, nonce)
+	return testStorage.Write(options.Label, append(prefix[:], data...))
+}
+
+func TestLoadWallet_Upgrade(t *testing.T) {
+	initTestStorage()
+
+	cases := []*Options{
+		{
+			Label:     "legacy0",
+			Seed:      testSeed,
+			Encrypted: true,
+			Password:  "password",
+		},
+		{
+			Label: "legacy1",
+			Seed:  testSeed,
+		},
+	}
+	for _, c := range cases {
+		require.NoError(t, saveLegacyWallet(c))
+
+		fw, err := loadWallet(c.Label, c.Password)
+		require.NoError(t, err)
+		require.Equal(t, uint64(0), fw.Meta.Version)
+		require.False(t, fw.Meta.Saved)
+		require.Equal(t, c.Seed, fw.Meta.Seed)
+		require.Equal(t, DeterministicKind, fw.Meta.Kind)
+		require.Equal(t, SkycoinDerivation, fw.Meta.Derivation)
+		require.Equal(t, []AccountStat{{Index: 0, Name: DefaultAccountName, EntryCount: 2}},
+			fw.ListAccounts())
+		for _, e := range fw.Entries {
+			require.NoError(t, e.Verify())
+			require.Equal(t, fw.Meta.TS, e.TS)
+			require.Empty(t, e.Label)
+		}
+
+		require.NoError(t, fw.Save(testStorage))
+		require.Equal(t, Version, fw.Meta.Version)
+
+		fw, err = loadWallet(c.Label, c.Password)
+		require.NoError(t, err)
+		require.Equal(t, Version, fw.Meta.Version)
+		require.True(t, fw.Meta.Saved)
+		require.Equal(t, c.Seed, fw.Meta.Seed)
+	}
+}
+
+func TestLoadWallet_Corrupt(t *testing.T) {
+	initTestStorage()
+
+	cases := []*Options{
+		{
+			Label:     "wallet0",
+			Seed:      testSeed,
+			Encrypted: true,
+			Password:  "password",
+		},
+		{
+			Label: "wallet1",
+			Seed:  testSeed,
+		},
+	}
+	for _, c := range cases {
+		require.NoError(t, saveWallet(c))
+		raw, err := testStorage.Read(c.Label)
+		require.NoError(t, err)
+
+		t.Run(c.Label+"_tampered", func(t *testing.T) {
+			tampered := append([]byte{}, raw...)
+			tampered[len(tampered)-40] ^= 0x01
+			_, err := LoadWallet(tampered, c.Label, c.Password)
+			require.Equal(t, ErrCorruptFile, err)
+		})
+
+		t.Run(c.Label+"_truncated", func(t *testing.T) {
+			_, err := LoadWallet(raw[:len(raw)-1], c.Label, c.Password)
+			require.Equal(t, ErrCorruptFile, err)
+		})
+	}
+}
+
+func TestWallet_EnsureEntries(t *testing.T) {
+	initTestStorage()
+
+	// Entries are compared by keys, as they are timestamped when generated.
+	keys := func(entries []Entry) []cipher.SecKey {
+		out := make([]cipher.SecKey, len(entries))
+		for i, e := range entries {
+			out[i] = e.SecKey
+		}
+		return out
+	}
+
+	for _, derivation := range []Derivation{SkycoinDerivation, HDDerivation} {
+		t.Run(string(derivation), func(t *testing.T) {
+			options := &Options{
+				Label:      "wallet_" + string(derivation),
+				Seed:       testSeed,
+				Passphrase: "passphrase",
+				Derivation: derivation,
+			}
+			full, err := NewWallet(options)
+			require.NoError(t, err)
+			require.NoError(t, full.EnsureEntries(12))
+			_, err = full.NewAccount("account", 6)
+			require.NoError(t, err)
+
+			// Entries are generated from where the chain stopped, also after
+			// the wallet is saved and loaded.
+			w, err := NewWallet(options)
+			require.NoError(t, err)
+			require.NoError(t, w.EnsureEntries(5))
+			_, err = w.NewAccount("account", 2)
+			require.NoError(t, err)
+			require.NoError(t, w.Save(testStorage))
+
+			w, err = loadWallet(options.Label, "")
+			require.NoError(t, err)
+			require.Equal(t, uint64(5), w.Accounts[0].Chain.Count)
+			require.NoError(t, w.SetPassphrase(options.Passphrase))
+			require.NoError(t, w.EnsureEntries(7))
+			require.NoError(t, w.EnsureEntries(12))
+			require.NoError(t, w.EnsureAccountEntries(1, 6))
+			require.Equal(t, keys(full.Entries), keys(w.Entries))
+			require.Equal(t, keys(full.Accounts[1].Entries), keys(w.Accounts[1].Entries))
+			require.Equal(t, full.Accounts[0].Chain, w.Accounts[0].Chain)
+			require.Equal(t, full.Accounts[1].Chain, w.Accounts[1].Chain)
+
+			// A chain that did not generate the entries is started over.
+			w.Entries = w.Entries[:3]
+			require.NoError(t, w.EnsureEntries(12))
+			require.Equal(t, keys(full.Entries), keys(w.Entries))
+			require.Equal(t, full.Accounts[0].Chain, w.Accounts[0].Chain)
+
+			// Entries are not generated with a different passphrase.
+			w.Meta.Passphrase = "other"
+			require.Equal(t, ErrInvalidPassphrase, w.EnsureEntries(13))
+			require.Len(t, w.Entries, 12)
+		})
+	}
+}
+
+func benchmarkEnsureEntries(b *testing.B, derivation Derivation, incremental bool) {
+	const n = 500
+	w, err := NewWallet(&Options{
+		Label:      "wallet",
+		Seed:       testSeed,
+		Derivation: derivation,
+	})
+	require.NoError(b, err)
+	require.NoError(b, w.EnsureEntries(n))
+	entries, chain := w.Entries, w.Accounts[0].Chain
+	if !incremental {
+		chain = Chain{}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.Entries, w.Accounts[0].Chain = entries, chain
+		if err := w.EnsureEntries(n + 1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// The benchmarks add an entry to a wallet of 500 entries, either from where
+// the chain stopped, or by generating the chain again.
+func BenchmarkEnsureEntries_Skycoin(b *testing.B) {
+	benchmarkEnsureEntries(b, SkycoinDerivation, true)
+}
+
+func BenchmarkEnsureEntries_SkycoinFull(b *testing.B) {
+	benchmarkEnsureEntries(b, SkycoinDerivation, false)
+}
+
+func BenchmarkEnsureEntries_HD(b *testing.B) {
+	benchmarkEnsureEntries(b, HDDerivation, true)
+}
+
+func BenchmarkEnsureEntries_HDFull(b *testing.B) {
+	benchmarkEnsureEntries(b, HDDerivation, false)
+}
@@ -0,0 +1,214 @@
+package wallet
+
+import (
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+const (
+	// DefaultWatchInterval is the default interval at which the wallet files
+	// are polled for external changes.
+	DefaultWatchInterval = 2 * time.Second
+
+	// maxChanges is the number of most recent changes the manager remembers.
+	maxChanges = 100
+)
+
+// ChangeOp determines how a wallet file was changed outside of the manager.
+type ChangeOp string
+
+const (
+	// ChangeAdded represents a wallet file that appeared.
+	ChangeAdded ChangeOp = "added"
+
+	// ChangeRemoved represents a wallet file that disappeared.
+	ChangeRemoved ChangeOp = "removed"
+
+	// ChangeReloaded represents a wallet file that was rewritten. The wallet
+	// is reloaded, and so is locked if encrypted. A wallet file that can no
+	// longer be loaded is quarantined.
+	ChangeReloaded ChangeOp = "reloaded"
+)
+
+// Change represents a change of a wallet file, that is detected by the
+// watcher. Seq increases with each change.
+type Change struct {
+	Seq   uint64   `json:"seq"`
+	Label string   `json:"label"`
+	Op    ChangeOp `json:"op"`
+	TS    int64    `json:"timestamp"`
+}
+
+// Changes lists the changes detected after the change of seq, oldest first,
+// and the seq of the latest change. Only the most recent changes are
+// remembered.
+func (m *Manager) Changes(seq uint64) ([]Change, uint64) {
+	defer m.lock()()
+
+	out := make([]Change, 0)
+	for _, c := range m.changes {
+		if c.Seq > seq {
+			out = append(out, c)
+		}
+	}
+	return out, m.seq
+}
+
+// watch polls the wallet files for changes made outside of the manager,
+// at the configured WatchInterval.
+func (m *Manager) watch() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.c.WatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.quit:
+			return
+		case <-ticker.C:
+			if err := m.poll(); err != nil {
+				log.Warningf("failed to poll wallet files: %v", err)
+			}
+		}
+	}
+}
+
+// poll compares the wallet files with the checksums of when they were last
+// read or written by the manager, and adds, removes or reloads only the
+// wallets of files that changed. Unchanged wallets keep their state.
+func (m *Manager) poll() error {
+	labels, err := m.c.Storage.List()
+	if err != nil {
+		return err
+	}
+	found := make(map[string]struct{}, len(labels))
+	for _, label := range labels {
+		found[label] = struct{}{}
+		m.pollFile(label)
+	}
+
+	m.mux.Lock()
+	var gone []string
+	for _, label := range m.labels {
+		if _, ok := found[label]; !ok {
+			gone = append(gone, label)
+		}
+	}
+	m.mux.Unlock()
+	for _, label := range gone {
+		m.pollFile(label)
+	}
+	return nil
+}
+
+// pollFile adds, removes or reloads the wallet of label, if it's file
+// changed. The file is compared first without the wallet's lock, so that
+// wallets in use are only waited for if their files changed.
+func (m *Manager) pollFile(label string) {
+	raw, err := m.c.Storage.Read(label)
+	m.mux.Lock()
+	unchanged := m.unchanged(label, raw, err)
+	m.mux.Unlock()
+	if unchanged {
+		return
+	}
+
+	unlock, lockErr := m.lockLabel(label, true)
+	if lockErr == nil {
+		defer unlock()
+	}
+	raw, err = m.c.Storage.Read(label)
+
+	defer m.lock()()
+
+	// A wallet that is listed without it's lock held was added while
+	// waiting, and is checked by the next poll.
+	_, listed := m.locks[label]
+	if listed != (lockErr == nil) || m.unchanged(label, raw, err) {
+		return
+	}
+	m.drop(label)
+	switch {
+	case err == ErrFileNotFound:
+		m.notifyChange(label, ChangeRemoved)
+		return
+	case err == nil:
+		err = m.load(label, raw)
+	}
+	if err != nil {
+		m.quarantine(label, err)
+	}
+	if listed {
+		m.notifyChange(label, ChangeReloaded)
+	} else {
+		m.notifyChange(label, ChangeAdded)
+	}
+	m.sort()
+}
+
+// unchanged returns true if the wallet file of label, as read with the given
+// data and error, is as it was last read or written by the manager. Files of
+// wallets being created are never changed.
+func (m *Manager) unchanged(label string, raw []byte, err error) bool {
+	if _, ok := m.reserved[label]; ok {
+		return true
+	}
+	_, listed := m.locks[label]
+	switch {
+	case err == ErrFileNotFound:
+		return !listed
+	case err != nil:
+		// Unreadable files are quarantined once, and retried when readable.
+		reason, ok := m.quarantined[label]
+		return ok && reason == err.Error()
+	default:
+		sum, ok := m.files[label]
+		return ok && sum == cipher.SumSHA256(raw)
+	}
+}
+
+// drop erases and removes the wallet of label, if it is listed. The wallet's
+// write lock and the index lock must be held.
+func (m *Manager) drop(label string) bool {
+	if w := m.wallets[label]; w != nil {
+		w.Erase()
+	}
+	return m.remove(label)
+}
+
+// track records the checksum of the wallet file of label as it is stored.
+func (m *Manager) track(label string) {
+	raw, err := m.c.Storage.Read(label)
+
+	defer m.lock()()
+	if err != nil {
+		// The file will be reloaded by the watcher.
+		delete(m.files, label)
+		return
+	}
+	m.files[label] = cipher.SumSHA256(raw)
+}
+
+// changeEvents determines the event that is published for each ChangeOp.
+var changeEvents = map[ChangeOp]EventType{
+	ChangeAdded:    EventCreated,
+	ChangeRemoved:  EventDeleted,
+	ChangeReloaded: EventReloaded,
+}
+
+func (m *Manager) notifyChange(label string, op ChangeOp) {
+	log.Infof("wallet file `%s` %s", label, op)
+	m.publish(Event{Type: changeEvents[op], Label: label})
+	m.seq++
+	m.changes = append(m.changes, Change{
+		Seq:   m.seq,
+		Label: label,
+		Op:    op,
+		TS:    time.Now().UnixNano(),
+	})
+	if len(m.changes) > maxChanges {
+		m.changes = m.changes[len(m.changes)-maxChanges:]
+	}
+}
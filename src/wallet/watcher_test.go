@@ -0,0 +1,92 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_Watch(t *testing.T) {
+	m, done := newTestManager(t)
+	defer done()
+
+	require.NoError(t, m.NewWallet(&Options{
+		Label:     "wallet0",
+		Seed:      testSeed,
+		Encrypted: true,
+		Password:  "password",
+	}, 2))
+	require.NoError(t, m.NewWallet(&Options{
+		Label: "wallet1",
+		Seed:  testSeed,
+	}, 2))
+
+	ops := func(changes []Change) map[string]ChangeOp {
+		out := make(map[string]ChangeOp)
+		for _, c := range changes {
+			out[c.Label] = c.Op
+		}
+		return out
+	}
+
+	t.Run("changes_of_manager", func(t *testing.T) {
+		require.NoError(t, m.RenameWallet("wallet1", "wallet2"))
+		require.NoError(t, m.RenameWallet("wallet2", "wallet1"))
+		require.NoError(t, m.ChangePassword("wallet0", "password", "new password"))
+		require.NoError(t, m.poll())
+
+		changes, seq := m.Changes(0)
+		require.Empty(t, changes)
+		require.Zero(t, seq)
+	})
+
+	t.Run("external_changes", func(t *testing.T) {
+		require.NoError(t, saveWallet(&Options{
+			Label: "wallet1",
+			Seed:  testSeed,
+		}))
+		require.NoError(t, saveWallet(&Options{
+			Label: "wallet2",
+			Seed:  testSeed,
+		}))
+		require.NoError(t, testStorage.Write("invalid", []byte{1, 2, 3}))
+		require.NoError(t, m.poll())
+
+		changes, seq := m.Changes(0)
+		require.Equal(t, uint64(3), seq)
+		require.Equal(t, map[string]ChangeOp{
+			"invalid": ChangeAdded,
+			"wallet1": ChangeReloaded,
+			"wallet2": ChangeAdded,
+		}, ops(changes))
+
+		// The unchanged wallet is still unlocked.
+		stats := m.ListWallets()
+		require.Len(t, stats, 4)
+		require.Equal(t, FileQuarantined, stats[0].Status)
+		require.Equal(t, "wallet0", stats[1].Label)
+		require.Equal(t, newBool(false), stats[1].Locked)
+
+		// Nothing changed since.
+		require.NoError(t, m.poll())
+		changes, _ = m.Changes(seq)
+		require.Empty(t, changes)
+
+		require.NoError(t, testStorage.Delete("wallet2"))
+		require.NoError(t, testStorage.Write("wallet0", []byte{1, 2, 3}))
+		require.NoError(t, m.poll())
+
+		changes, seq = m.Changes(seq)
+		require.Equal(t, uint64(5), seq)
+		require.Equal(t, map[string]ChangeOp{
+			"wallet0": ChangeReloaded,
+			"wallet2": ChangeRemoved,
+		}, ops(changes))
+
+		stats = m.ListWallets()
+		require.Len(t, stats, 3)
+		require.Equal(t, "wallet0", stats[1].Label)
+		require.Equal(t, FileQuarantined, stats[1].Status)
+		require.Equal(t, "wallet1", stats[2].Label)
+	})
+}
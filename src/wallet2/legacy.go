@@ -0,0 +1,184 @@
+package wallet2
+
+import (
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// entryV6 is the Entry stored in wallet files of versions 0 to 6.
+type entryV6 struct {
+	Address cipher.Address
+	PubKey  cipher.PubKey
+	SecKey  cipher.SecKey
+}
+
+// migrateEntries migrates entries to the latest Entry. The entries are
+// taken as added at ts, when the wallet was created.
+func migrateEntries(entries []entryV6, ts int64) []Entry {
+	if entries == nil {
+		return nil
+	}
+	out := make([]Entry, len(entries))
+	for i, e := range entries {
+		out[i] = Entry{
+			Address: e.Address,
+			PubKey:  e.PubKey,
+			SecKey:  e.SecKey,
+			TS:      ts,
+		}
+	}
+	return out
+}
+
+// metaV2 is the Meta stored in wallet files of versions 0 to 2.
+type metaV2 struct {
+	AssetType AssetType
+	Seed      string
+	TS        int64
+}
+
+// fileV2 is the File stored in wallet files of versions 0 to 2.
+type fileV2 struct {
+	Meta    metaV2
+	Entries []entryV6
+}
+
+func (f *fileV2) migrate() Model {
+	return &fileV3{
+		Meta: metaV4{
+			AssetType: f.Meta.AssetType,
+			Kind:      DeterministicKind,
+			Seed:      f.Meta.Seed,
+			TS:        f.Meta.TS,
+		},
+		Entries: f.Entries,
+	}
+}
+
+// metaV4 is the Meta stored in wallet files of versions 3 and 4.
+type metaV4 struct {
+	AssetType AssetType
+	Kind      Kind
+	Seed      string
+	TS        int64
+}
+
+// fileV3 is the File stored in wallet files of version 3.
+type fileV3 struct {
+	Meta    metaV4
+	Entries []entryV6
+}
+
+func (f *fileV3) migrate() Model {
+	return &fileV4{
+		Meta:    f.Meta,
+		Entries: f.Entries,
+	}
+}
+
+// fileV4 is the File stored in wallet files of version 4.
+type fileV4 struct {
+	Meta     metaV4
+	Entries  []entryV6
+	Imported []entryV6
+}
+
+func (f *fileV4) migrate() Model {
+	var derivation Derivation
+	if f.Meta.Kind == DeterministicKind {
+		derivation = SkycoinDerivation
+	}
+	return &fileV5{
+		Meta: Meta{
+			AssetType:  f.Meta.AssetType,
+			Kind:       f.Meta.Kind,
+			Derivation: derivation,
+			Seed:       f.Meta.Seed,
+			TS:         f.Meta.TS,
+		},
+		Entries:  f.Entries,
+		Imported: f.Imported,
+	}
+}
+
+// fileV5 is the File stored in wallet files of version 5.
+type fileV5 struct {
+	Meta     Meta
+	Entries  []entryV6
+	Imported []entryV6
+}
+
+func (f *fileV5) migrate() Model {
+	return &fileV6{
+		Meta:     f.Meta,
+		Entries:  f.Entries,
+		Imported: f.Imported,
+		Accounts: []accountV6{{Index: 0, Name: DefaultAccountName}},
+	}
+}
+
+// accountV6 is the Account stored in wallet files of version 6.
+type accountV6 struct {
+	Index   uint32
+	Name    string
+	Entries []entryV6
+}
+
+// fileV6 is the File stored in wallet files of version 6.
+type fileV6 struct {
+	Meta     Meta
+	Entries  []entryV6
+	Imported []entryV6
+	Accounts []accountV6
+}
+
+func (f *fileV6) migrate() Model {
+	ts := f.Meta.TS
+	accounts := make([]accountV7, len(f.Accounts))
+	for i, a := range f.Accounts {
+		accounts[i] = accountV7{
+			Index:   a.Index,
+			Name:    a.Name,
+			Entries: migrateEntries(a.Entries, ts),
+		}
+	}
+	return &fileV7{
+		Meta:     f.Meta,
+		Entries:  migrateEntries(f.Entries, ts),
+		Imported: migrateEntries(f.Imported, ts),
+		Accounts: accounts,
+	}
+}
+
+// accountV7 is the Account stored in wallet files of version 7.
+type accountV7 struct {
+	Index   uint32
+	Name    string
+	Entries []Entry
+}
+
+// fileV7 is the File stored in wallet files of version 7.
+type fileV7 struct {
+	Meta     Meta
+	Entries  []Entry
+	Imported []Entry
+	Accounts []accountV7
+}
+
+// migrate leaves the Chain of accounts empty, so that their entries are
+// generated again the next time more are needed.
+func (f *fileV7) migrate() Model {
+	accounts := make([]Account, len(f.Accounts))
+	for i, a := range f.Accounts {
+		accounts[i] = Account{
+			Index:   a.Index,
+			Name:    a.Name,
+			Entries: a.Entries,
+		}
+	}
+	return &File{
+		Meta:     f.Meta,
+		Entries:  f.Entries,
+		Imported: f.Imported,
+		Accounts: accounts,
+	}
+}
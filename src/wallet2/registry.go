@@ -0,0 +1,81 @@
+package wallet2
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+)
+
+var (
+	ErrUnsupportedVersion = errors.New(
+		"wallet file is of a newer version than is supported, a newer release is needed to open it")
+)
+
+// Model is the body of a wallet file of some version, as stored.
+type Model interface {
+	// migrate converts the model to the model of the next version. The File
+	// of the latest Version returns itself.
+	migrate() Model
+}
+
+// decoders holds, for each supported version, a function that creates the
+// empty Model to decode the body of a wallet file of that version into.
+// Versions 0 to 2 only differ in how the body is encrypted.
+var decoders = map[uint64]func() Model{
+	0:       func() Model { return new(fileV2) },
+	1:       func() Model { return new(fileV2) },
+	2:       func() Model { return new(fileV2) },
+	3:       func() Model { return new(fileV3) },
+	4:       func() Model { return new(fileV4) },
+	5:       func() Model { return new(fileV5) },
+	6:       func() Model { return new(fileV6) },
+	7:       func() Model { return new(fileV7) },
+	Version: func() Model { return new(File) },
+}
+
+// Supported returns true if wallet files of the version can be decoded.
+func Supported(version uint64) bool {
+	_, ok := decoders[version]
+	return ok
+}
+
+// Decode decodes the body of a wallet file of the given version, and
+// migrates it through every following version to the latest File.
+// ErrUnsupportedVersion is returned for versions newer than Version.
+func Decode(version uint64, b []byte) (*File, error) {
+	newModel, ok := decoders[version]
+	if !ok {
+		if version > Version {
+			return nil, ErrUnsupportedVersion
+		}
+		return nil, fmt.Errorf("wallet file version %d has no decoder", version)
+	}
+	m := newModel()
+	if err := deserializeRaw(b, m); err != nil {
+		return nil, err
+	}
+	return Migrate(m), nil
+}
+
+// Migrate migrates a model through every following version to the latest
+// File.
+func Migrate(m Model) *File {
+	for {
+		if f, ok := m.(*File); ok {
+			return f
+		}
+		m = m.migrate()
+	}
+}
+
+// deserializeRaw is encoder.DeserializeRaw, but recovers from the panics
+// that happen on malformed data.
+func deserializeRaw(b []byte, out interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to read wallet file: %v", r)
+		}
+	}()
+	return encoder.DeserializeRaw(b, out)
+}
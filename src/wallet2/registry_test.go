@@ -0,0 +1,79 @@
+package wallet2
+
+import (
+	"testing"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+	pk, sk := cipher.GenerateKeyPair()
+	entry := entryV6{
+		Address: cipher.AddressFromPubKey(pk),
+		PubKey:  pk,
+		SecKey:  sk,
+	}
+	meta := Meta{
+		AssetType:  KittyAsset,
+		Kind:       DeterministicKind,
+		Derivation: SkycoinDerivation,
+		Seed:       "seed",
+		TS:         1000,
+	}
+	models := map[uint64]Model{
+		0: &fileV2{
+			Meta:    metaV2{AssetType: KittyAsset, Seed: "seed", TS: 1000},
+			Entries: []entryV6{entry},
+		},
+		3: &fileV3{
+			Meta:    metaV4{AssetType: KittyAsset, Kind: DeterministicKind, Seed: "seed", TS: 1000},
+			Entries: []entryV6{entry},
+		},
+		5: &fileV5{
+			Meta:    meta,
+			Entries: []entryV6{entry},
+		},
+		6: &fileV6{
+			Meta:     meta,
+			Entries:  []entryV6{entry},
+			Accounts: []accountV6{{Index: 0, Name: DefaultAccountName}},
+		},
+		7: &fileV7{
+			Meta: meta,
+			Entries: []Entry{{
+				Address: entry.Address,
+				PubKey:  entry.PubKey,
+				SecKey:  entry.SecKey,
+				TS:      1000,
+			}},
+			Accounts: []accountV7{{Index: 0, Name: DefaultAccountName}},
+		},
+	}
+	for version, m := range models {
+		f, err := Decode(version, encoder.Serialize(m))
+		require.NoError(t, err, version)
+		require.Equal(t, &File{
+			Meta: meta,
+			Entries: []Entry{{
+				Address: entry.Address,
+				PubKey:  entry.PubKey,
+				SecKey:  entry.SecKey,
+				TS:      1000,
+			}},
+			Accounts: []Account{{Index: 0, Name: DefaultAccountName}},
+		}, f, version)
+
+		// Files of the latest version decode as they are.
+		latest, err := Decode(Version, f.Serialize())
+		require.NoError(t, err)
+		require.Equal(t, f, latest)
+	}
+
+	_, err := Decode(Version+1, nil)
+	require.Equal(t, ErrUnsupportedVersion, err)
+	require.False(t, Supported(Version+1))
+	_, err = Decode(Version, []byte{1, 2, 3})
+	require.Error(t, err)
+}
@@ -1,9 +1,9 @@
+// Package wallet2 is the versioned on-disk model of wallet files. It decodes
+// the (decrypted) body of a wallet file of any supported version, and
+// migrates it to the File of the latest Version.
 package wallet2
 
 import (
-	"errors"
-	"fmt"
-
 	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/skycoin/skycoin/src/cipher/encoder"
 )
@@ -11,52 +11,116 @@ import (
 type (
 	// AssetType determines the asset type that the wallet holds.
 	AssetType string
-)
 
-var (
-	ErrInvalidPassword    = errors.New("invalid password")
-	ErrInvalidCredentials = errors.New("failed to read wallet file, maybe due to incorrect credentials")
+	// Kind determines how the entries of a wallet are obtained.
+	Kind string
+
+	// Derivation determines how the entries of a deterministic wallet are
+	// generated from it's seed.
+	Derivation string
+
+	// Extension determines a file's extension.
+	Extension string
 )
 
 const (
 	// Version determines the wallet file's version.
-	Version uint64 = 0
+	//	- Version 0: key is the SHA256 of the password.
+	//	- Version 1: key is derived with scrypt, KDFParams follow the Prefix.
+	//	- Version 2: data is authenticated.
+	//	- Version 3: Meta has Kind, watch-only entries have no secret keys.
+	//	- Version 4: File has Imported entries.
+	//	- Version 5: Meta has Derivation.
+	//	- Version 6: File has Accounts.
+	//	- Version 7: Entry has Label, Note, TS and Hidden.
+	//	- Version 8: Account has Chain.
+	Version uint64 = 8
 
 	// KittyAsset represents the "kittycash" asset type.
 	KittyAsset AssetType = "kittycash"
 
+	// DeterministicKind represents a wallet with entries generated from it's seed.
+	DeterministicKind Kind = "deterministic"
+
+	// WatchOnlyKind represents a wallet with no seed, that holds only addresses
+	// and (optionally) public keys.
+	WatchOnlyKind Kind = "watch_only"
+
+	// SkycoinDerivation generates entries with the skycoin deterministic key
+	// chain, where each key's seed is the hash of the previous one.
+	SkycoinDerivation Derivation = "skycoin"
+
+	// HDDerivation generates entries with BIP32 hierarchical deterministic
+	// derivation, under the BIP44 path m/44'/8000'/account'/chain/index.
+	HDDerivation Derivation = "bip44"
+
+	// DefaultAccountName is the name of the first account of a wallet.
+	DefaultAccountName = "default"
+
 	// FileExt is the kittycash file extension.
-	FileExt = ".kcw"
+	FileExt Extension = ".kcw"
 )
 
-// Meta represents wallet meta that's stored on disk.
+// Meta represents the meta that is stored in file.
 type Meta struct {
-	AssetType AssetType `json:"type"`
-	Seed      string    `json:"seed"`
-	TS        int64     `json:"timestamp"`
+	AssetType  AssetType  `json:"type"`
+	Kind       Kind       `json:"kind"`
+	Derivation Derivation `json:"derivation"`
+	Seed       string     `json:"seed"`
+	TS         int64      `json:"timestamp"`
 }
 
-// Entry represents a wallet entry that is stored on disk.
+// Entry represents a wallet entry that is stored in file.
 type Entry struct {
 	Address cipher.Address
 	PubKey  cipher.PubKey
 	SecKey  cipher.SecKey
+
+	// Label, Note and Hidden are set by the user, to remember what the
+	// address is used for. Hidden entries are still part of the wallet, but
+	// are not meant to be shown.
+	Label  string
+	Note   string
+	TS     int64 // When the entry was added.
+	Hidden bool
+}
+
+// Chain represents where the key chain of an account stopped, so that more
+// entries can be generated without generating the existing ones again.
+// Count is the number of keys generated, Seed is the seed of the next key of
+// SkycoinDerivation, and Next is the child index of the next key of
+// HDDerivation. The zero Chain has generated no keys.
+type Chain struct {
+	Count uint64
+	Seed  cipher.SHA256
+	Next  uint32
 }
 
-// Wallet represents a wallet that is stored on disk.
-type Wallet struct {
-	Meta    Meta
+// Account represents a named account of a wallet that is stored in file.
+// The entries of the first account are the Entries of the File.
+type Account struct {
+	Index   uint32
+	Name    string
 	Entries []Entry
+	Chain   Chain
+}
+
+// File represents the wallet that is stored in file, of the latest Version.
+type File struct {
+	Meta     Meta
+	Entries  []Entry
+	Imported []Entry
+	Accounts []Account
+}
+
+// Serialize encodes the File as the body of a wallet file of the latest
+// Version.
+func (f File) Serialize() []byte {
+	return encoder.Serialize(f)
 }
 
-// WalletFromRaw loads a wallet from raw bytes.
-func WalletFromRaw(b []byte) (w *Wallet, err error) {
-	w = new(Wallet)
-	defer func() {
-		if r := recover(); r != nil {
-			err = fmt.Errorf("failed to read wallet file: %v", r)
-		}
-	}()
-	err = encoder.DeserializeRaw(b, w)
-	return
+// migrate implements Model. File is of the latest Version, so it is never
+// migrated.
+func (f *File) migrate() Model {
+	return f
 }
@@ -60,6 +60,10 @@ export class ScratchCardComponent implements OnInit {
         for (let i = 0; i < wallets.length; i++)
         {
           let wallet = wallets[i];
+          if (!wallet)
+          {
+            continue;
+          }
 
           for (let x = 0; x < wallet.entries.length; x++)
           {
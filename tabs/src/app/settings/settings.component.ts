@@ -39,16 +39,6 @@ export class SettingsComponent implements OnInit {
     this.dialogRef.close();
   }
 
-  unlockWallet(wallet: any)
-  {
-    let __this = this;
-    this.settingsService.getWalletDetails(wallet.label, wallet.password).then(function(success){
-      __this.refreshWalletList();
-    }).catch(function(err){
-       alert(err);
-    });
-  }
-
   walletsToRestore() {
 
     let success = false;
@@ -64,7 +54,7 @@ export class SettingsComponent implements OnInit {
 
     for (let i = 0; i < this.restore_wallets_list.length; i++)
     {
-      if (this.restore_wallets_list[i].restore && this.restore_wallets_list[i].encrypted && (!this.restore_wallets_list[i].password || (this.restore_wallets_list[i].password && this.restore_wallets_list[i].password.length <= 0)))
+      if (this.restore_wallets_list[i].restore && !this.restore_wallets_list[i].file && this.restore_wallets_list[i].encrypted && (!this.restore_wallets_list[i].password || (this.restore_wallets_list[i].password && this.restore_wallets_list[i].password.length <= 0)))
       {
         return false;
       }
@@ -94,24 +84,16 @@ export class SettingsComponent implements OnInit {
         wallets.push(this.wallets_list[i]);
       }
     }
-    //Get the wallet data
-    this.settingsService.getBackupFile(wallets).then(wallets => {
-      //Now prepare the data in our backup format.
+    //Get the wallet backups
+    this.settingsService.getBackupFile(wallets).then(backups => {
+      //Wallets that could not be exported are left out.
 
       let data = [];
-      for (let i = 0; i < wallets.length; i++)
+      for (let i = 0; i < backups.length; i++)
       {
-        let wallet = wallets[i];
-        if (wallet)
+        if (backups[i])
         {
-          let obj = {
-            label: wallet.meta.label, 
-            seed: wallet.meta.seed, 
-            version: wallet.meta.version, 
-            aCount: wallet.entry_count, 
-            encrypted: wallet.meta.encrypted
-          };
-          data.push(obj);
+          data.push(backups[i]);
         } 
       }
 
@@ -147,7 +129,15 @@ export class SettingsComponent implements OnInit {
            try {
              let backup = JSON.parse(reader.result);
 
-             __this.restore_wallets_list = backup;
+             //Wallet backups are imported as they are, while older backup
+             //files hold the seeds of the wallets.
+             __this.restore_wallets_list = backup.map(function(wallet) {
+               if (wallet.format)
+               {
+                 return {label: wallet.label, encrypted: wallet.encrypted, file: wallet};
+               }
+               return wallet;
+             });
 
            } catch(e) {
              alert("Invalid backup file.");
@@ -185,12 +175,30 @@ export class SettingsComponent implements OnInit {
        {
          let wallet = wallets[i];
 
+         let restored = function(result: any) {
+          let refresh_event = new CustomEvent('refreshButtonClick', { cancelable: true, detail: {} });
+          document.dispatchEvent(refresh_event);
+          complete = complete + 1;
+          if (complete == wallets.length)
+          {
+             __this.dialogRef.close();
+          }
+         };
+
+         if (wallet.file)
+         {
+           __this.settingsService.importBackup(wallet.file, wallet.label).subscribe(restored);
+           continue;
+         }
+
          let params = {
           label: wallet.label,
           seed: wallet.seed,
           aCount: wallet.aCount,
           encrypted: wallet.encrypted,
-          password: null
+          password: null,
+          //Older wallets may have seeds that are not BIP39 mnemonics.
+          allowRawSeed: true
         };
 
         if (params.encrypted)
@@ -198,16 +206,7 @@ export class SettingsComponent implements OnInit {
           params.password = wallet.password;
         }
 
-        __this.settingsService.restoreSeed(params).subscribe((result: any) => { 
-          let refresh_event = new CustomEvent('refreshButtonClick', { cancelable: true, detail: {} });
-          document.dispatchEvent(refresh_event);
-          complete = complete + 1;
-          if (complete == wallets.length)
-          {
-             __this.dialogRef.close();
-          }
-
-        });
+        __this.settingsService.restoreSeed(params).subscribe(restored);
        }
      }
      else
@@ -222,7 +221,9 @@ export class SettingsComponent implements OnInit {
       seed: this.restore_seed,
       aCount: 1,
       encrypted: false,
-      password: null
+      password: null,
+      //Seeds that look like mistyped mnemonics are still refused.
+      allowRawSeed: true
     };
     this.settingsService.restoreSeed(params).subscribe((result: any) => { 
       let refresh_event = new CustomEvent('refreshButtonClick', { cancelable: true, detail: {} });
@@ -8,7 +8,9 @@ import { environment } from '../../environments/environment';
 const routes = {
   new_wallet: (s: WalletContext) => `http://127.0.0.1:6148/v1/wallets/new`,
   list_wallets: () => `http://127.0.0.1:6148/v1/wallets/list`,
-  get_wallet: () => `http://127.0.0.1:6148/v1/wallets/get`
+  get_wallet: () => `http://127.0.0.1:6148/v1/wallets/get`,
+  export_wallet: () => `http://127.0.0.1:6148/v1/wallets/export`,
+  import_wallet: () => `http://127.0.0.1:6148/v1/wallets/import`
 };
 
 export interface WalletContext {
@@ -17,6 +19,7 @@ export interface WalletContext {
   aCount: number;
   encrypted: boolean;
   password: string;
+  allowRawSeed?: boolean;
 }
 
 @Injectable()
@@ -33,22 +36,24 @@ export class SettingsService {
       );
   }
 
-  getBackupFile(wallets): Promise<any> {
-    return new Promise<any>((resolve, reject) => {
-      let promises = [];
+  importBackup(backup: any, label: string): Observable<object> {
+    return this.httpClient
+      .post(routes.import_wallet(), this.getQueryString({backup: JSON.stringify(backup), label: label}), this.getOptions())
+      .pipe(
+        map((body: any) => body),
+        catchError(() => of('Error, could not import backup :-('))
+      );
+  }
 
-      for (let i = 0; i < wallets.length; i++)
-      {
-        let wallet = wallets[i];
-        promises.push(this.getWalletDetails(wallet.label, null));
-      }
-      Promise.all(promises).then(wallets=> {
-        resolve(wallets);
-      }, 
-      error => {
-        alert(error);
-      })
-    });
+  getBackupFile(wallets): Promise<any> {
+    // Wallet files are exported as stored, so encrypted wallets stay
+    // encrypted and do not need to be unlocked.
+    let promises = [];
+    for (let i = 0; i < wallets.length; i++)
+    {
+      promises.push(this.exportWallet(wallets[i].label));
+    }
+    return Promise.all(promises);
   }
 
   getWalletList(): Promise<any> {
@@ -69,6 +74,20 @@ export class SettingsService {
     });
   }
 
+  exportWallet(label: string): Promise<any> {
+    return new Promise<any>((resolve, reject) => {
+      this.httpClient
+      .post(routes.export_wallet(), this.getQueryString({label: label}), this.getOptions()).subscribe(
+        (backup: any) => {
+          resolve(backup);
+        }, (err: any) => {
+          alert("Warning: The wallet: " + label + " could not be exported.  It will not be included in the backup file.");
+          resolve(false);
+        }
+      );
+    });
+  }
+
   getWalletDetails(label: string, password: any): Promise<any> {
     return new Promise<any>((resolve, reject) => {
       this.httpClient